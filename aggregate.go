@@ -0,0 +1,92 @@
+package ipcritbit
+
+import (
+	"net/netip"
+)
+
+// Aggregate returns a new routing table with the routes summarized:
+// subnets with the same value as their nearest supernet are dropped and
+// sibling prefixes with equal values are merged, e.g. 10.0.0.0/25 and
+// 10.0.0.128/25 to 10.0.0.0/24. The LookupIP results are the same as for t.
+//
+// If equal is nil all values are considered equal, the result is the
+// minimal set of prefixes covering the same address space as t, each with
+// the value of the first merged route in canonical order.
+func (t RouteTable[V]) Aggregate(equal func(a, b V) bool) RouteTable[V] {
+	if equal == nil {
+		equal = func(V, V) bool { return true }
+	}
+	return RouteTable[V]{
+		tree4:  aggregateTree(t.tree4, equal),
+		tree6:  aggregateTree(t.tree6, equal),
+		strict: t.strict,
+	}
+}
+
+func aggregateTree[V any](t *critBitTree[V], equal func(V, V) bool) *critBitTree[V] {
+	r := newTree[V]()
+	var insert func(nodes []*pfxTree[V])
+	insert = func(nodes []*pfxTree[V]) {
+		for _, n := range nodes {
+			var buf [maxKeyLen]byte
+			r.insert(pfxToKey(&buf, n.pfx), n.val)
+			insert(n.subs)
+		}
+	}
+	insert(aggregate(t.hierarchy(), nil, equal))
+	return r
+}
+
+// aggregate summarizes the nodes, the direct subnets of parent or the roots
+// if parent is nil. The subnets of each node are aggregated first, bottom-up.
+//
+// The result is built on a stack in canonical order, a node and the top of
+// the stack are merged as long as they are siblings with equal values.
+// Nodes with the value of parent are dropped, their subnets take their place.
+func aggregate[V any](nodes []*pfxTree[V], parent *pfxTree[V], equal func(V, V) bool) []*pfxTree[V] {
+	var stack []*pfxTree[V]
+
+	var push func(n *pfxTree[V])
+	push = func(n *pfxTree[V]) {
+		for {
+			if parent != nil && equal(n.val, parent.val) {
+				for _, s := range n.subs {
+					push(s)
+				}
+				return
+			}
+			if len(stack) == 0 {
+				break
+			}
+			top := stack[len(stack)-1]
+			super, ok := siblings(top.pfx, n.pfx)
+			if !ok || !equal(top.val, n.val) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+			n = &pfxTree[V]{pfx: super, val: top.val, subs: append(top.subs, n.subs...)}
+		}
+		stack = append(stack, n)
+	}
+
+	for _, n := range nodes {
+		n.subs = aggregate(n.subs, n, equal)
+		// the subnets cover n completely, the value of n is shadowed
+		if len(n.subs) == 1 && n.subs[0].pfx == n.pfx {
+			n.val, n.subs = n.subs[0].val, n.subs[0].subs
+		}
+		push(n)
+	}
+	return stack
+}
+
+// siblings reports whether a and b are the lower and upper half of the
+// same prefix, the common supernet is returned.
+func siblings(a, b netip.Prefix) (netip.Prefix, bool) {
+	bits := a.Bits()
+	if bits == 0 || bits != b.Bits() || a == b {
+		return netip.Prefix{}, false
+	}
+	super, _ := a.Addr().Prefix(bits - 1)
+	return super, super.Contains(b.Addr())
+}
@@ -0,0 +1,120 @@
+package ipcritbit_test
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func TestAggregate(t *testing.T) {
+	equal := func(a, b string) bool { return a == b }
+
+	tests := []struct {
+		routes map[string]string
+		equal  func(a, b string) bool
+		want   []string
+	}{
+		{
+			routes: map[string]string{"10.0.0.0/25": "a", "10.0.0.128/25": "a"},
+			equal:  equal,
+			want:   []string{"10.0.0.0/24 a"},
+		},
+		{
+			routes: map[string]string{"10.0.0.0/25": "a", "10.0.0.128/25": "b"},
+			equal:  equal,
+			want:   []string{"10.0.0.0/25 a", "10.0.0.128/25 b"},
+		},
+		{
+			routes: map[string]string{"10.0.0.0/25": "a", "10.0.0.128/25": "b"},
+			want:   []string{"10.0.0.0/24 a"},
+		},
+		{
+			// cascading merges
+			routes: map[string]string{
+				"10.0.0.0/24": "a", "10.0.1.0/25": "a", "10.0.1.128/25": "a",
+				"10.0.2.0/23": "a", "10.0.4.0/24": "a",
+			},
+			equal: equal,
+			want:  []string{"10.0.0.0/22 a", "10.0.4.0/24 a"},
+		},
+		{
+			// subnets with the value of the supernet
+			routes: map[string]string{
+				"10.0.0.0/8": "a", "10.1.0.0/16": "a", "10.1.1.0/24": "b",
+				"10.1.1.0/25": "a", "10.2.0.0/16": "c",
+			},
+			equal: equal,
+			want:  []string{"10.0.0.0/8 a", "10.1.1.0/24 b", "10.1.1.0/25 a", "10.2.0.0/16 c"},
+		},
+		{
+			routes: map[string]string{
+				"10.0.0.0/8": "a", "10.1.0.0/16": "a", "10.1.1.0/24": "b",
+				"10.1.1.0/25": "a", "10.2.0.0/16": "c", "11.0.0.0/8": "d",
+			},
+			want: []string{"10.0.0.0/7 a"},
+		},
+		{
+			// the merged subnets shadow the supernet
+			routes: map[string]string{
+				"10.0.0.0/23": "a", "10.0.0.0/24": "b", "10.0.1.0/24": "b",
+				"10.0.1.0/25": "c",
+			},
+			equal: equal,
+			want:  []string{"10.0.0.0/23 b", "10.0.1.0/25 c"},
+		},
+		{
+			routes: map[string]string{
+				"2001:db8::/33": "a", "2001:db8:8000::/33": "a", "::/1": "b", "8000::/1": "b",
+			},
+			equal: equal,
+			want:  []string{"::/0 b", "2001:db8::/32 a"},
+		},
+	}
+
+	for _, tt := range tests {
+		rtbl := buildAlgebra(tt.routes)
+		want := rtbl.String()
+		checkRoutes(t, "Aggregate()", rtbl.Aggregate(tt.equal), tt.want)
+		if rtbl.String() != want {
+			t.Errorf("Aggregate() - table modified")
+		}
+	}
+}
+
+// TestAggregateRandom compares the lookup results of the aggregated and the
+// original table for random addresses.
+func TestAggregateRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		rtbl := randomTable(random, 1_000, 3)
+
+		agg := rtbl.Aggregate(func(a, b int) bool { return a == b })
+		all := rtbl.Aggregate(nil)
+		if agg.Size() >= rtbl.Size() || all.Size() > agg.Size() {
+			t.Fatalf("Aggregate() - sizes %d, %d, %d", rtbl.Size(), agg.Size(), all.Size())
+		}
+		if hasSubnets(all) {
+			t.Fatalf("Aggregate(nil) - overlapping prefixes")
+		}
+
+		checkRandomLookups(t, "Aggregate()", random, 5_000, lookupOf(agg), lookupOf(rtbl))
+		checkRandomLookups(t, "Aggregate(nil)", random, 5_000, lookupOf(all), func(ip netip.Addr) (int, bool) {
+			// any value of the merged routes
+			_, v, _ := all.Lookup(ip)
+			return v, rtbl.Contains(ip)
+		})
+	}
+}
+
+func hasSubnets(rtbl ipcritbit.RouteTable[int]) bool {
+	for pfx := range rtbl.All() {
+		for sub := range rtbl.Subnets(pfx) {
+			if sub != pfx {
+				return true
+			}
+		}
+	}
+	return false
+}
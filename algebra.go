@@ -0,0 +1,116 @@
+package ipcritbit
+
+import (
+	"net/netip"
+)
+
+// Union returns a new routing table with the routes of t and o.
+// For prefixes in both tables resolve is called with the values from t and o,
+// the returned value is stored. If resolve is nil, the value from t is kept.
+func (t RouteTable[V]) Union(o RouteTable[V], resolve func(pfx netip.Prefix, a, b V) V) RouteTable[V] {
+	u := t.Clone()
+	unionTree(u.tree4, o.tree4, resolve)
+	unionTree(u.tree6, o.tree6, resolve)
+	return u
+}
+
+func unionTree[V any](dst, src *critBitTree[V], resolve func(netip.Prefix, V, V) V) {
+	src.walk(func(key []byte, b V) bool {
+		if a, ok := dst.get(key); ok {
+			if resolve == nil {
+				return true
+			}
+			b = resolve(keyToPfx(key), a, b)
+		}
+		dst.set(key, b)
+		return true
+	})
+}
+
+// Intersection returns a new routing table covering the address space
+// covered by both t and o. Routes of t are split into the subnets of o where
+// needed, the values are taken from t, with the same LookupIP results as t
+// for all addresses in the intersection.
+func (t RouteTable[V]) Intersection(o RouteTable[V]) RouteTable[V] {
+	return RouteTable[V]{
+		tree4:  intersectTree(t.tree4, o.tree4),
+		tree6:  intersectTree(t.tree6, o.tree6),
+		strict: t.strict,
+	}
+}
+
+// intersectTree: every address in both trees is in a route of a covered by b
+// or in a route of b covered by a, the latter gets the value of the longest
+// match in a.
+func intersectTree[V any](a, b *critBitTree[V]) *critBitTree[V] {
+	r := newTree[V]()
+	if a.items == 0 || b.items == 0 {
+		return r
+	}
+	a.walk(func(key []byte, value V) bool {
+		if b.covers(key) {
+			r.insert(key, value)
+		}
+		return true
+	})
+	b.walk(func(key []byte, _ V) bool {
+		if n := lookup(&a.root, key, false); n != nil {
+			r.set(key, n.external.value)
+		}
+		return true
+	})
+	return r
+}
+
+// Difference returns a new routing table covering the address space of t
+// not covered by o. Routes of t are split around the routes of o where
+// needed, the values are taken from t, with the same LookupIP results as t
+// for all remaining addresses.
+func (t RouteTable[V]) Difference(o RouteTable[V]) RouteTable[V] {
+	return RouteTable[V]{
+		tree4:  differenceTree(t.tree4, o.tree4),
+		tree6:  differenceTree(t.tree6, o.tree6),
+		strict: t.strict,
+	}
+}
+
+func differenceTree[V any](a, b *critBitTree[V]) *critBitTree[V] {
+	r := newTree[V]()
+	a.walk(func(key []byte, value V) bool {
+		var buf [maxKeyLen]byte
+		n := copy(buf[:], key)
+		subtract(r, a, b, buf[:n], value, true)
+		return true
+	})
+	return r
+}
+
+// subtract inserts the parts of key not covered by b into r. key is halved
+// as long as it overlaps b, a more specific route in a stops the descent, it
+// is subtracted on its own.
+func subtract[V any](r, a, b *critBitTree[V], key []byte, value V, top bool) {
+	switch {
+	case b.covers(key):
+		return
+	case !top && a.contains(key):
+		return
+	case !b.overlaps(key):
+		r.insert(key, value)
+		return
+	}
+
+	// b has a subnet of key, split key in both halves
+	last := len(key) - 1
+	mask := key[last]
+
+	var hi [maxKeyLen]byte
+	copy(hi[:], key)
+	hi[mask/8] |= 0x80 >> (mask % 8)
+	hi[last] = mask + 1
+
+	lo := key
+	lo[last] = mask + 1
+
+	subtract(r, a, b, lo, value, false)
+	subtract(r, a, b, hi[:len(key)], value, false)
+}
@@ -0,0 +1,212 @@
+package ipcritbit_test
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func buildAlgebra(routes map[string]string) ipcritbit.RouteTable[string] {
+	rtbl := ipcritbit.New[string]()
+	for pfx, val := range routes {
+		rtbl.Add(netip.MustParsePrefix(pfx), val)
+	}
+	return rtbl
+}
+
+func checkRoutes(t *testing.T, name string, rtbl ipcritbit.RouteTable[string], want []string) {
+	t.Helper()
+	var got []string
+	rtbl.Walk(func(pfx netip.Prefix, val string) bool {
+		got = append(got, pfx.String()+" "+val)
+		return true
+	})
+	if len(got) != len(want) {
+		t.Fatalf("%s - expected %v, got %v", name, want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s - expected %v, got %v", name, want, got)
+			return
+		}
+	}
+}
+
+func TestUnion(t *testing.T) {
+	a := buildAlgebra(map[string]string{
+		"10.0.0.0/8":     "a1",
+		"192.168.0.0/16": "a2",
+		"2001:db8::/32":  "a3",
+	})
+	b := buildAlgebra(map[string]string{
+		"10.0.0.0/8":    "b1",
+		"10.1.0.0/16":   "b2",
+		"fe80::/10":     "b3",
+		"2001:db8::/32": "b4",
+	})
+
+	u := a.Union(b, func(pfx netip.Prefix, x, y string) string { return x + "+" + y })
+	checkRoutes(t, "Union()", u, []string{
+		"10.0.0.0/8 a1+b1",
+		"10.1.0.0/16 b2",
+		"192.168.0.0/16 a2",
+		"2001:db8::/32 a3+b4",
+		"fe80::/10 b3",
+	})
+
+	u = a.Union(b, nil)
+	checkRoutes(t, "Union(nil)", u, []string{
+		"10.0.0.0/8 a1",
+		"10.1.0.0/16 b2",
+		"192.168.0.0/16 a2",
+		"2001:db8::/32 a3",
+		"fe80::/10 b3",
+	})
+
+	if a.Size() != 3 || b.Size() != 4 {
+		t.Errorf("Union() - operands modified")
+	}
+}
+
+func TestIntersection(t *testing.T) {
+	a := buildAlgebra(map[string]string{
+		"10.0.0.0/8":     "a1",
+		"10.1.0.0/16":    "a2",
+		"192.168.0.0/16": "a3",
+		"2001:db8::/32":  "a4",
+	})
+	b := buildAlgebra(map[string]string{
+		"10.0.0.0/9":     "b1",
+		"10.1.2.0/24":    "b2",
+		"172.16.0.0/12":  "b3",
+		"192.168.1.0/24": "b4",
+		"::/0":           "b5",
+	})
+
+	checkRoutes(t, "Intersection()", a.Intersection(b), []string{
+		"10.0.0.0/9 a1",
+		"10.1.0.0/16 a2",
+		"10.1.2.0/24 a2",
+		"192.168.1.0/24 a3",
+		"2001:db8::/32 a4",
+	})
+
+	checkRoutes(t, "Intersection()", b.Intersection(a), []string{
+		"10.0.0.0/9 b1",
+		"10.1.0.0/16 b1",
+		"10.1.2.0/24 b2",
+		"192.168.1.0/24 b4",
+		"2001:db8::/32 b5",
+	})
+
+	if got := a.Intersection(ipcritbit.New[string]()).Size(); got != 0 {
+		t.Errorf("Intersection() - expected empty table, got %d routes", got)
+	}
+}
+
+func TestDifference(t *testing.T) {
+	a := buildAlgebra(map[string]string{
+		"10.0.0.0/8":    "a1",
+		"10.0.0.0/16":   "a2",
+		"2001:db8::/32": "a3",
+		"fe80::/10":     "a4",
+	})
+	b := buildAlgebra(map[string]string{
+		"10.0.0.0/24":   "b1",
+		"10.128.0.0/9":  "b2",
+		"2001:db8::/33": "b3",
+		"fe80::/9":      "b4",
+	})
+
+	checkRoutes(t, "Difference()", a.Difference(b), []string{
+		"10.0.1.0/24 a2",
+		"10.0.2.0/23 a2",
+		"10.0.4.0/22 a2",
+		"10.0.8.0/21 a2",
+		"10.0.16.0/20 a2",
+		"10.0.32.0/19 a2",
+		"10.0.64.0/18 a2",
+		"10.0.128.0/17 a2",
+		"10.1.0.0/16 a1",
+		"10.2.0.0/15 a1",
+		"10.4.0.0/14 a1",
+		"10.8.0.0/13 a1",
+		"10.16.0.0/12 a1",
+		"10.32.0.0/11 a1",
+		"10.64.0.0/10 a1",
+		"2001:db8:8000::/33 a3",
+	})
+}
+
+func randomAddr(random *rand.Rand) netip.Addr {
+	if random.Intn(2) == 0 {
+		var a4 [4]byte
+		random.Read(a4[:])
+		return netip.AddrFrom4(a4)
+	}
+	var a16 [16]byte
+	random.Read(a16[:])
+	return netip.AddrFrom16(a16)
+}
+
+// randomTable returns a table with n random routes, see randomPrefix, and
+// random values below nvals.
+func randomTable(random *rand.Rand, n, nvals int) ipcritbit.RouteTable[int] {
+	rtbl := ipcritbit.New[int]()
+	for i := 0; i < n; i++ {
+		rtbl.Add(randomPrefix(random), random.Intn(nvals))
+	}
+	return rtbl
+}
+
+// lookupFunc is the signature of the lookups compared by checkRandomLookups.
+type lookupFunc func(netip.Addr) (int, bool)
+
+func lookupOf(rtbl ipcritbit.RouteTable[int]) lookupFunc {
+	return func(ip netip.Addr) (int, bool) {
+		_, v, ok := rtbl.Lookup(ip)
+		return v, ok
+	}
+}
+
+// checkRandomLookups compares got and want for n random addresses, the
+// values only for hits. Differential test of a table derived from another.
+func checkRandomLookups(t *testing.T, name string, random *rand.Rand, n int, got, want lookupFunc) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		ip := randomAddr(random)
+		wantV, wantOK := want(ip)
+		if v, ok := got(ip); ok != wantOK || ok && v != wantV {
+			t.Fatalf("%s - %s: expected (%d, %v), got (%d, %v)", name, ip, wantV, wantOK, v, ok)
+		}
+	}
+}
+
+// TestAlgebraRandom compares the lookup results of the set operations with
+// the lookup results of the operands.
+func TestAlgebraRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		a, b := randomTable(random, 200, 1<<30), randomTable(random, 200, 1<<30)
+
+		checkRandomLookups(t, "Intersection()", random, 2_000, lookupOf(a.Intersection(b)), func(ip netip.Addr) (int, bool) {
+			_, v, ok := a.Lookup(ip)
+			return v, ok && b.Contains(ip)
+		})
+		checkRandomLookups(t, "Difference()", random, 2_000, lookupOf(a.Difference(b)), func(ip netip.Addr) (int, bool) {
+			_, v, ok := a.Lookup(ip)
+			return v, ok && !b.Contains(ip)
+		})
+		// the longer match wins, a on equal prefixes
+		checkRandomLookups(t, "Union()", random, 2_000, lookupOf(a.Union(b, nil)), func(ip netip.Addr) (int, bool) {
+			pa, va, okA := a.Lookup(ip)
+			pb, vb, okB := b.Lookup(ip)
+			if okA && (!okB || pa.Bits() >= pb.Bits()) {
+				return va, true
+			}
+			return vb, okB
+		})
+	}
+}
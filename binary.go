@@ -0,0 +1,276 @@
+package ipcritbit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// binary format, all routes in canonical order:
+//
+//	+-------+---------+-------+--------+--------+---------+---------+
+//	| magic | version | flags | uvarint n4 | uvarint n6 | routes... |
+//	+-------+---------+-------+--------+--------+---------+---------+
+//
+//	route: | bits | significant address bytes | uvarint len | value |
+//
+// The number of address bytes follows from bits, host bytes are omitted.
+// The default gob codec writes all values as one gob stream, the type
+// information is sent only with the first value.
+const (
+	binaryMagic   = "ICBT"
+	binaryVersion = 1
+
+	flagStrict = 1 << 0
+)
+
+var (
+	// ErrInvalidEncoding is returned for malformed binary data.
+	ErrInvalidEncoding = errors.New("ipcritbit: invalid encoding")
+
+	// ErrUnsupportedVersion is returned for binary data from a newer format version.
+	ErrUnsupportedVersion = errors.New("ipcritbit: unsupported encoding version")
+)
+
+var (
+	_ encoding.BinaryMarshaler   = RouteTable[any]{}
+	_ encoding.BinaryUnmarshaler = (*RouteTable[any])(nil)
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// Values are encoded by their own MarshalBinary method if *V implements
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, otherwise with
+// encoding/gob. A value type implementing only one of the two is rejected.
+// For RouteTable[any] the concrete value types must be registered with
+// gob.Register, basic types excepted. Use MarshalBinaryFunc for a custom
+// value codec.
+func (t RouteTable[V]) MarshalBinary() ([]byte, error) {
+	return t.MarshalBinaryFunc(newValueEncoder[V]())
+}
+
+// MarshalBinaryFunc is like MarshalBinary with a custom value encoder.
+// The zero value RouteTable is encoded as empty table.
+func (t RouteTable[V]) MarshalBinaryFunc(encode func(V) ([]byte, error)) ([]byte, error) {
+	t = t.orEmpty()
+	b := make([]byte, 0, 16+t.Size()*8)
+	b = append(b, binaryMagic...)
+	b = append(b, binaryVersion)
+	var flags byte
+	if t.strict {
+		flags |= flagStrict
+	}
+	b = append(b, flags)
+	b = binary.AppendUvarint(b, uint64(t.tree4.items))
+	b = binary.AppendUvarint(b, uint64(t.tree6.items))
+
+	var err error
+	t.Walk(func(p netip.Prefix, value V) bool {
+		var vb []byte
+		if vb, err = encode(value); err != nil {
+			err = fmt.Errorf("ipcritbit: encoding value of %s: %w", p, err)
+			return false
+		}
+		bits := p.Bits()
+		b = append(b, byte(bits))
+		b = append(b, p.Addr().AsSlice()[:(bits+7)/8]...)
+		b = binary.AppendUvarint(b, uint64(len(vb)))
+		b = append(b, vb...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, the routes of t are
+// replaced. See MarshalBinary for the value codec.
+func (t *RouteTable[V]) UnmarshalBinary(data []byte) error {
+	return t.UnmarshalBinaryFunc(data, newValueDecoder[V]())
+}
+
+// UnmarshalBinaryFunc is like UnmarshalBinary with a custom value decoder.
+// On error t is left unchanged.
+func (t *RouteTable[V]) UnmarshalBinaryFunc(data []byte, decode func([]byte) (V, error)) error {
+	d := decoder{data: data}
+	if magic := d.next(len(binaryMagic)); string(magic) != binaryMagic {
+		return fmt.Errorf("%w: bad magic", ErrInvalidEncoding)
+	}
+	if version := d.byte(); d.err == nil && version != binaryVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	nt := New[V]()
+	nt.strict = d.byte()&flagStrict != 0
+	n4 := d.uvarint()
+	n6 := d.uvarint()
+
+	for i := uint64(0); d.err == nil && i < n4+n6; i++ {
+		var a16 [16]byte
+		addrLen, maxBits := 4, 32
+		if i >= n4 {
+			addrLen, maxBits = 16, 128
+		}
+
+		bits := int(d.byte())
+		if bits > maxBits {
+			return fmt.Errorf("%w: prefix length %d", ErrInvalidEncoding, bits)
+		}
+		copy(a16[:], d.next((bits+7)/8))
+		vb := d.next(int(d.uvarint()))
+		if d.err != nil {
+			break
+		}
+
+		addr := netip.AddrFrom16(a16)
+		if addrLen == 4 {
+			addr = netip.AddrFrom4([4]byte(a16[:4]))
+		}
+		p := netip.PrefixFrom(addr, bits)
+		if p.Masked() != p {
+			return fmt.Errorf("%w: host bits set in %s", ErrInvalidEncoding, p)
+		}
+
+		value, err := decode(vb)
+		if err != nil {
+			return fmt.Errorf("ipcritbit: decoding value of %s: %w", p, err)
+		}
+		if err := nt.TryAdd(p, value); err != nil {
+			return err
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, len(d.data))
+	}
+	*t = nt
+	return nil
+}
+
+// decoder consumes data, the first error is sticky.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.err = fmt.Errorf("%w: unexpected end of data", ErrInvalidEncoding)
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	u, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = fmt.Errorf("%w: bad uvarint", ErrInvalidEncoding)
+		return 0
+	}
+	d.data = d.data[n:]
+	return u
+}
+
+// newValueEncoder returns the default value encoder, see MarshalBinary.
+// The gob encoder is kept for all values, the returned slice is only valid
+// until the next call.
+func newValueEncoder[V any]() func(V) ([]byte, error) {
+	if binaryCodec[V]() {
+		return func(value V) ([]byte, error) {
+			return any(&value).(encoding.BinaryMarshaler).MarshalBinary()
+		}
+	}
+	if err := checkGob[V](); err != nil {
+		return func(V) ([]byte, error) { return nil, err }
+	}
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	return func(value V) ([]byte, error) {
+		buf.Reset()
+		// pointer to value, interface values are sent with their type
+		if err := enc.Encode(&value); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// newValueDecoder returns the default value decoder, the counterpart of
+// newValueEncoder. The values must be decoded in the encoding order.
+func newValueDecoder[V any]() func([]byte) (V, error) {
+	if binaryCodec[V]() {
+		return func(b []byte) (V, error) {
+			var value V
+			err := any(&value).(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+			return value, err
+		}
+	}
+	if err := checkGob[V](); err != nil {
+		return func([]byte) (V, error) {
+			var zero V
+			return zero, err
+		}
+	}
+	var r bytes.Reader
+	dec := gob.NewDecoder(&r)
+	return func(b []byte) (V, error) {
+		var value V
+		r.Reset(b)
+		if err := dec.Decode(&value); err != nil {
+			return value, err
+		}
+		if r.Len() != 0 {
+			return value, fmt.Errorf("%w: %d trailing value bytes", ErrInvalidEncoding, r.Len())
+		}
+		return value, nil
+	}
+}
+
+// binaryCodec reports whether *V implements encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, both are required for the value's own codec.
+func binaryCodec[V any]() bool {
+	var value V
+	_, m := any(&value).(encoding.BinaryMarshaler)
+	_, u := any(&value).(encoding.BinaryUnmarshaler)
+	return m && u
+}
+
+// checkGob rejects value types with a marshaler method but without the
+// matching unmarshaler, or vice versa. gob uses these methods, the encoded
+// values could not be decoded.
+func checkGob[V any]() error {
+	var value V
+	p := any(&value)
+	_, ge := p.(gob.GobEncoder)
+	_, gd := p.(gob.GobDecoder)
+	_, bm := p.(encoding.BinaryMarshaler)
+	_, bu := p.(encoding.BinaryUnmarshaler)
+	_, tm := p.(encoding.TextMarshaler)
+	_, tu := p.(encoding.TextUnmarshaler)
+	if ge != gd || bm != bu || tm != tu {
+		return fmt.Errorf("ipcritbit: value type %T: marshaler and unmarshaler methods must be implemented in pairs", value)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package ipcritbit_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"strconv"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+// marshalOnly implements encoding.BinaryMarshaler without the unmarshaler.
+type marshalOnly struct{ N int }
+
+func (m *marshalOnly) MarshalBinary() ([]byte, error) {
+	return []byte{byte(m.N)}, nil
+}
+
+// unmarshalOnly implements encoding.BinaryUnmarshaler without the marshaler.
+type unmarshalOnly struct{ N int }
+
+func (m *unmarshalOnly) UnmarshalBinary(b []byte) error {
+	m.N = len(b)
+	return nil
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	data, err := rtbl.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - unexpected error: %v", err)
+	}
+
+	var got ipcritbit.RouteTable[string]
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - unexpected error: %v", err)
+	}
+	if got.String() != rtbl.String() {
+		t.Errorf("UnmarshalBinary() - expected:\n%s\ngot:\n%s", rtbl, got)
+	}
+	checkMatchIP(t, got, "192.168.1.35", "192.168.1.32/30")
+	checkMatchIP(t, got, "2001:db8:0:1::", "2001:db8::/32")
+
+	// replaces the routes
+	other := ipcritbit.New[string]()
+	other.Add(netip.MustParsePrefix("172.16.0.0/12"), "stale")
+	if err := other.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - unexpected error: %v", err)
+	}
+	if _, ok := other.Get(netip.MustParsePrefix("172.16.0.0/12")); ok || other.Size() != rtbl.Size() {
+		t.Errorf("UnmarshalBinary() - routes not replaced: %d", other.Size())
+	}
+
+	// empty table
+	data, err = ipcritbit.New[string]().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - empty table: %v", err)
+	}
+	if err := got.UnmarshalBinary(data); err != nil || got.Size() != 0 {
+		t.Errorf("UnmarshalBinary() - empty table: %v, %d", err, got.Size())
+	}
+
+	// zero table, encoded as empty table
+	zero, err := ipcritbit.RouteTable[string]{}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - zero table: %v", err)
+	}
+	if !bytes.Equal(zero, data) {
+		t.Errorf("MarshalBinary() - zero table: expected %x, got %x", data, zero)
+	}
+}
+
+func TestBinaryValues(t *testing.T) {
+	pfx := netip.MustParsePrefix("10.0.0.0/8")
+
+	// gob, interface values with basic types
+	anyTbl := ipcritbit.New[any]()
+	anyTbl.Add(pfx, 42)
+	anyTbl.Add(netip.MustParsePrefix("::/0"), "default")
+	anyTbl.Add(netip.MustParsePrefix("192.168.0.0/16"), nil)
+	data, err := anyTbl.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - any: %v", err)
+	}
+	var anyGot ipcritbit.RouteTable[any]
+	if err := anyGot.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - any: %v", err)
+	}
+	if v, _ := anyGot.Get(pfx); v != 42 {
+		t.Errorf("UnmarshalBinary() - any: expected 42, got %v", v)
+	}
+	if v, ok := anyGot.Get(netip.MustParsePrefix("192.168.0.0/16")); v != nil || !ok {
+		t.Errorf("UnmarshalBinary() - any: expected nil, got %v, %v", v, ok)
+	}
+
+	// encoding.BinaryMarshaler
+	addrTbl := ipcritbit.New[netip.Addr]()
+	gw := netip.MustParseAddr("192.0.2.1")
+	addrTbl.Add(pfx, gw)
+	data, err = addrTbl.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - netip.Addr: %v", err)
+	}
+	var addrGot ipcritbit.RouteTable[netip.Addr]
+	if err := addrGot.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - netip.Addr: %v", err)
+	}
+	if v, _ := addrGot.Get(pfx); v != gw {
+		t.Errorf("UnmarshalBinary() - netip.Addr: expected %s, got %s", gw, v)
+	}
+
+	// struct values share one gob stream, the type is sent only once
+	krTbl := ipcritbit.New[ipcritbit.KernelRoute]()
+	for i := 0; i < 100; i++ {
+		p := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), 0, 0}), 16)
+		krTbl.Add(p, ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("192.0.2.1"), Interface: "eth0", Metric: i})
+	}
+	data, err = krTbl.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - KernelRoute: %v", err)
+	}
+	if len(data) > 100*30 {
+		t.Errorf("MarshalBinary() - KernelRoute: expected at most 30 bytes per route, got %d", len(data)/100)
+	}
+	var krGot ipcritbit.RouteTable[ipcritbit.KernelRoute]
+	if err := krGot.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - KernelRoute: %v", err)
+	}
+	if krGot.String() != krTbl.String() {
+		t.Errorf("UnmarshalBinary() - KernelRoute: expected:\n%s\ngot:\n%s", krTbl, krGot)
+	}
+
+	// one-sided codecs are rejected on encoding and on decoding, the data
+	// is a valid gob stream of the same struct
+	moTbl := ipcritbit.New[marshalOnly]()
+	moTbl.Add(pfx, marshalOnly{N: 1})
+	if _, err := moTbl.MarshalBinary(); err == nil {
+		t.Errorf("MarshalBinary() - marshal only: expected error")
+	}
+	uoTbl := ipcritbit.New[unmarshalOnly]()
+	uoTbl.Add(pfx, unmarshalOnly{N: 1})
+	if _, err := uoTbl.MarshalBinary(); err == nil {
+		t.Errorf("MarshalBinary() - unmarshal only: expected error")
+	}
+
+	type plain struct{ N int }
+	plainTbl := ipcritbit.New[plain]()
+	plainTbl.Add(pfx, plain{N: 1})
+	data, err = plainTbl.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - unexpected error: %v", err)
+	}
+	if err := moTbl.UnmarshalBinary(data); err == nil {
+		t.Errorf("UnmarshalBinary() - marshal only: expected error")
+	}
+	if err := uoTbl.UnmarshalBinary(data); err == nil {
+		t.Errorf("UnmarshalBinary() - unmarshal only: expected error")
+	}
+
+	// custom codec
+	intTbl := ipcritbit.New[int]()
+	intTbl.Add(pfx, -7)
+	data, err = intTbl.MarshalBinaryFunc(func(v int) ([]byte, error) {
+		return binary.AppendVarint(nil, int64(v)), nil
+	})
+	if err != nil {
+		t.Fatalf("MarshalBinaryFunc() - unexpected error: %v", err)
+	}
+	var intGot ipcritbit.RouteTable[int]
+	err = intGot.UnmarshalBinaryFunc(data, func(b []byte) (int, error) {
+		v, _ := binary.Varint(b)
+		return int(v), nil
+	})
+	if err != nil {
+		t.Fatalf("UnmarshalBinaryFunc() - unexpected error: %v", err)
+	}
+	if v, _ := intGot.Get(pfx); v != -7 {
+		t.Errorf("UnmarshalBinaryFunc() - expected -7, got %v", v)
+	}
+
+	// codec errors
+	errCodec := errors.New("codec")
+	if _, err := intTbl.MarshalBinaryFunc(func(int) ([]byte, error) { return nil, errCodec }); !errors.Is(err, errCodec) {
+		t.Errorf("MarshalBinaryFunc() - expected codec error, got %v", err)
+	}
+	if err := intGot.UnmarshalBinaryFunc(data, func([]byte) (int, error) { return 0, errCodec }); !errors.Is(err, errCodec) {
+		t.Errorf("UnmarshalBinaryFunc() - expected codec error, got %v", err)
+	}
+}
+
+func TestBinaryStrict(t *testing.T) {
+	rtbl := ipcritbit.NewStrict[int]()
+	rtbl.Add(netip.MustParsePrefix("10.0.0.0/8"), 1)
+	data, _ := rtbl.MarshalBinary()
+
+	var got ipcritbit.RouteTable[int]
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - unexpected error: %v", err)
+	}
+	if err := got.TryAdd(netip.MustParsePrefix("10.1.2.3/8"), 2); !errors.Is(err, ipcritbit.ErrNonCanonicalPrefix) {
+		t.Errorf("UnmarshalBinary() - strict mode lost: %v", err)
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	rtbl := buildTestNetip(t)
+	data, _ := rtbl.MarshalBinary()
+
+	got := ipcritbit.New[string]()
+
+	// every truncation must fail cleanly
+	for i := 0; i < len(data); i++ {
+		if err := got.UnmarshalBinary(data[:i]); !errors.Is(err, ipcritbit.ErrInvalidEncoding) {
+			t.Fatalf("UnmarshalBinary() - truncated at %d: %v", i, err)
+		}
+	}
+	if got.Size() != 0 {
+		t.Errorf("UnmarshalBinary() - table modified on error: %d", got.Size())
+	}
+
+	if err := got.UnmarshalBinary(append(data, 0)); !errors.Is(err, ipcritbit.ErrInvalidEncoding) {
+		t.Errorf("UnmarshalBinary() - trailing bytes: %v", err)
+	}
+
+	bad := append([]byte{}, data...)
+	bad[4] = 99
+	if err := got.UnmarshalBinary(bad); !errors.Is(err, ipcritbit.ErrUnsupportedVersion) {
+		t.Errorf("UnmarshalBinary() - version: %v", err)
+	}
+
+	// one IPv4 route with empty value: 10.0.0.0/8, 10.192.0.0/9, 10.0.0.0/33
+	decode := func(b []byte) (string, error) { return string(b), nil }
+	valid := []byte{'I', 'C', 'B', 'T', 1, 0, 1, 0, 8, 10, 0}
+	if err := got.UnmarshalBinaryFunc(valid, decode); err != nil {
+		t.Fatalf("UnmarshalBinary() - unexpected error: %v", err)
+	}
+	hostBits := []byte{'I', 'C', 'B', 'T', 1, 0, 1, 0, 9, 10, 192, 0}
+	if err := got.UnmarshalBinaryFunc(hostBits, decode); !errors.Is(err, ipcritbit.ErrInvalidEncoding) {
+		t.Errorf("UnmarshalBinary() - host bits: %v", err)
+	}
+	tooLong := []byte{'I', 'C', 'B', 'T', 1, 0, 1, 0, 33, 10, 0, 0, 0, 0, 0}
+	if err := got.UnmarshalBinaryFunc(tooLong, decode); !errors.Is(err, ipcritbit.ErrInvalidEncoding) {
+		t.Errorf("UnmarshalBinary() - prefix length: %v", err)
+	}
+}
+
+func BenchmarkBinary(b *testing.B) {
+	rtbl := ipcritbit.New[string]()
+	for i, p := range cidrs {
+		rtbl.Add(p, strconv.Itoa(i))
+	}
+	encode := func(v string) ([]byte, error) { return []byte(v), nil }
+	decode := func(b []byte) (string, error) { return string(b), nil }
+	data, _ := rtbl.MarshalBinaryFunc(encode)
+
+	b.Run("Marshal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = rtbl.MarshalBinaryFunc(encode)
+		}
+	})
+	b.Run("Unmarshal", func(b *testing.B) {
+		var got ipcritbit.RouteTable[string]
+		for i := 0; i < b.N; i++ {
+			_ = got.UnmarshalBinaryFunc(data, decode)
+		}
+	})
+}
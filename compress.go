@@ -0,0 +1,286 @@
+package ipcritbit
+
+import (
+	"net/netip"
+)
+
+// Compress returns a new routing table with the same LookupIP results as t
+// and a minimal number of routes, computed with the Optimal Routing Table
+// Constructor (ORTC) of Draves, King, Venkatachary and Zill. Values are
+// compared with equal, if equal is nil all values are considered equal.
+//
+// The address space not covered by t stays uncovered, the compression works
+// on each region covered by the top-level routes or by adjacent top-level
+// routes, e.g. 10.0.0.0/9 and 10.128.0.0/9.
+func (t RouteTable[V]) Compress(equal func(a, b V) bool) RouteTable[V] {
+	if equal == nil {
+		equal = func(V, V) bool { return true }
+	}
+	return RouteTable[V]{
+		tree4:  compressTree(t.tree4, equal),
+		tree6:  compressTree(t.tree6, equal),
+		strict: t.strict,
+	}
+}
+
+// ortcNode is a node of the path compressed binary trie of a covered
+// region, only routes and branch points are nodes. child 0 and 1 are in
+// the lower and upper half of the node's prefix, at any depth below.
+type ortcNode[V any] struct {
+	key   [maxKeyLen]byte // masked, followed by the depth, see pfxToKey
+	child [2]*ortcNode[V]
+	route bool
+	val   V
+	set   []V
+}
+
+func (n *ortcNode[V]) depth(klen int) int {
+	return int(n.key[klen-1])
+}
+
+// region is an address block completely covered by the top-level routes.
+type region[V any] struct {
+	pfx    netip.Prefix
+	routes []*pfxTree[V]
+}
+
+// ortc is the compression state of one tree, the result is inserted into r.
+type ortc[V any] struct {
+	r     *critBitTree[V]
+	klen  int
+	equal func(V, V) bool
+}
+
+func compressTree[V any](t *critBitTree[V], equal func(V, V) bool) *critBitTree[V] {
+	o := ortc[V]{r: newTree[V](), equal: equal}
+	for _, reg := range regions(t.hierarchy()) {
+		root := &ortcNode[V]{}
+		o.klen = len(pfxToKey(&root.key, reg.pfx))
+
+		var insert func(nodes []*pfxTree[V])
+		insert = func(nodes []*pfxTree[V]) {
+			for _, n := range nodes {
+				var buf [maxKeyLen]byte
+				o.insert(root, pfxToKey(&buf, n.pfx), n.val)
+				insert(n.subs)
+			}
+		}
+		insert(reg.routes)
+
+		// the root of a region is a route or has both halves covered, the
+		// zero value is never inherited
+		var zero V
+		o.normalize(root, zero)
+		o.emit(root, zero, false, zero)
+	}
+	return o.r
+}
+
+// regions merges adjacent top-level routes to covered regions, on a stack
+// in canonical order as long as the top and the next region are siblings.
+func regions[V any](roots []*pfxTree[V]) []region[V] {
+	var stack []region[V]
+	for _, n := range roots {
+		reg := region[V]{pfx: n.pfx, routes: []*pfxTree[V]{n}}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			super, ok := siblings(top.pfx, reg.pfx)
+			if !ok {
+				break
+			}
+			stack = stack[:len(stack)-1]
+			reg = region[V]{pfx: super, routes: append(top.routes, reg.routes...)}
+		}
+		stack = append(stack, reg)
+	}
+	return stack
+}
+
+// insert the route key below n, a node is split at the first differing bit.
+func (o *ortc[V]) insert(n *ortcNode[V], key []byte, val V) {
+	depth := int(key[o.klen-1])
+	for {
+		nd := n.depth(o.klen)
+		if depth == nd {
+			n.route, n.val = true, val
+			return
+		}
+		i := keyBit(key, nd)
+		c := n.child[i]
+		if c == nil {
+			n.child[i] = o.newNode(key, depth, true, val)
+			return
+		}
+
+		// the bits up to nd are equal, key and c are in the same half of n
+		cd := c.depth(o.klen)
+		l := nd + 1
+		for l < min(depth, cd) && keyBit(key, l) == keyBit(c.key[:], l) {
+			l++
+		}
+		if l == cd {
+			n = c
+			continue
+		}
+
+		var zero V
+		m := o.newNode(key, l, l == depth, val)
+		if l < depth {
+			m.route, m.val = false, zero
+			m.child[keyBit(key, l)] = o.newNode(key, depth, true, val)
+		}
+		m.child[keyBit(c.key[:], l)] = c
+		n.child[i] = m
+		return
+	}
+}
+
+func (o *ortc[V]) newNode(key []byte, depth int, route bool, val V) *ortcNode[V] {
+	n := &ortcNode[V]{route: route, val: val}
+	copy(n.key[:], key)
+	n.key = o.prefix(n.key, depth)
+	return n
+}
+
+// normalize is the first and second ORTC pass, bottom-up each node gets
+// the set of candidate values, the intersection of the sets of both halves
+// if not empty, else the union.
+//
+// In the binary trie missing halves are leaves with the inherited value x.
+// A half with the next node further below is a chain of single child
+// nodes, each with a leaf x as other half. Just above the next node the set
+// is combined with x, further up the set is x alone.
+func (o *ortc[V]) normalize(n *ortcNode[V], x V) {
+	if n.route {
+		x = n.val
+	}
+	if n.child[0] == nil && n.child[1] == nil {
+		n.set = []V{x}
+		return
+	}
+	var halves [2][]V
+	for i, c := range n.child {
+		if c == nil {
+			halves[i] = []V{x}
+			continue
+		}
+		o.normalize(c, x)
+		switch c.depth(o.klen) - n.depth(o.klen) {
+		case 1:
+			halves[i] = c.set
+		case 2:
+			halves[i] = o.combine(c.set, []V{x})
+		default:
+			halves[i] = []V{x}
+		}
+	}
+	n.set = o.combine(halves[0], halves[1])
+}
+
+// combine returns the intersection of a and b if not empty, else the union.
+func (o *ortc[V]) combine(a, b []V) []V {
+	var set []V
+	for _, v := range a {
+		if member(b, v, o.equal) {
+			set = append(set, v)
+		}
+	}
+	if len(set) > 0 {
+		return set
+	}
+	set = append(set, a...)
+	for _, v := range b {
+		if !member(a, v, o.equal) {
+			set = append(set, v)
+		}
+	}
+	return set
+}
+
+// emit is the third ORTC pass: top-down a route is inserted only if the
+// inherited value y is not in the set of the node, also for the virtual
+// nodes and leaves of the binary trie, see normalize. x is the inherited
+// value of the original routes.
+func (o *ortc[V]) emit(n *ortcNode[V], y V, ok bool, x V) {
+	if !ok || !member(n.set, y, o.equal) {
+		y, ok = n.set[0], true
+		o.add(n.key, y)
+	}
+	if n.route {
+		x = n.val
+	}
+	if n.child[0] == nil && n.child[1] == nil {
+		return
+	}
+
+	d := n.depth(o.klen)
+	for i, c := range n.child {
+		if c == nil {
+			// leaf x
+			if !o.equal(y, x) {
+				o.add(o.half(n.key, d, i), x)
+			}
+			continue
+		}
+
+		yc := y
+		cd := c.depth(o.klen)
+		for j := d + 1; j < cd; j++ {
+			// virtual node on the chain to c
+			if j < cd-1 {
+				if !o.equal(yc, x) {
+					yc = x
+					o.add(o.prefix(c.key, j), yc)
+				}
+			} else if set := o.combine(c.set, []V{x}); !member(set, yc, o.equal) {
+				yc = set[0]
+				o.add(o.prefix(c.key, j), yc)
+			}
+			// its leaf x, the half away from c
+			if !o.equal(yc, x) {
+				o.add(o.half(c.key, j, 1-keyBit(c.key[:], j)), x)
+			}
+		}
+		o.emit(c, yc, true, x)
+	}
+}
+
+// add inserts the route key into the result.
+func (o *ortc[V]) add(key [maxKeyLen]byte, val V) {
+	o.r.insert(key[:o.klen], val)
+}
+
+// prefix returns key shortened to depth.
+func (o *ortc[V]) prefix(key [maxKeyLen]byte, depth int) [maxKeyLen]byte {
+	if depth%8 != 0 {
+		key[depth/8] &= 0xff << (8 - depth%8)
+	}
+	for i := (depth + 7) / 8; i < o.klen-1; i++ {
+		key[i] = 0
+	}
+	key[o.klen-1] = byte(depth)
+	return key
+}
+
+// half returns the lower or upper half of key shortened to depth.
+func (o *ortc[V]) half(key [maxKeyLen]byte, depth, bit int) [maxKeyLen]byte {
+	key = o.prefix(key, depth)
+	key[o.klen-1] = byte(depth + 1)
+	if bit == 1 {
+		key[depth/8] |= 0x80 >> (depth % 8)
+	}
+	return key
+}
+
+func keyBit(key []byte, i int) int {
+	return int(key[i/8]>>(7-i%8)) & 1
+}
+
+func member[V any](set []V, v V, equal func(V, V) bool) bool {
+	for _, x := range set {
+		if equal(x, v) {
+			return true
+		}
+	}
+	return false
+}
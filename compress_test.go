@@ -0,0 +1,95 @@
+package ipcritbit_test
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func TestCompress(t *testing.T) {
+	equal := func(a, b string) bool { return a == b }
+
+	tests := []struct {
+		routes map[string]string
+		want   []string
+	}{
+		{
+			routes: map[string]string{},
+			want:   nil,
+		},
+		{
+			routes: map[string]string{"10.0.0.0/8": "a", "10.1.0.0/16": "a"},
+			want:   []string{"10.0.0.0/8 a"},
+		},
+		{
+			// the supernet value is replaced by the value of the majority
+			routes: map[string]string{"10.0.0.0/8": "a", "10.0.0.0/9": "b", "10.128.0.0/10": "b"},
+			want:   []string{"10.0.0.0/8 b", "10.192.0.0/10 a"},
+		},
+		{
+			// adjacent top-level routes
+			routes: map[string]string{"10.0.0.0/9": "a", "10.128.0.0/9": "a", "11.0.0.0/8": "b"},
+			want:   []string{"10.0.0.0/7 a", "11.0.0.0/8 b"},
+		},
+		{
+			// uncovered address space stays uncovered
+			routes: map[string]string{"10.0.0.0/8": "a", "12.0.0.0/8": "a", "192.168.0.0/16": "b"},
+			want:   []string{"10.0.0.0/8 a", "12.0.0.0/8 a", "192.168.0.0/16 b"},
+		},
+		{
+			routes: map[string]string{
+				"::/0": "a", "2001:db8::/32": "b", "2001:db8::/33": "a", "2001:db8:8000::/34": "a",
+				"2001:db8:c000::/34": "a",
+			},
+			want: []string{"::/0 a"},
+		},
+	}
+
+	for _, tt := range tests {
+		rtbl := buildAlgebra(tt.routes)
+		want := rtbl.String()
+		checkRoutes(t, "Compress()", rtbl.Compress(equal), tt.want)
+		if rtbl.String() != want {
+			t.Errorf("Compress() - table modified")
+		}
+	}
+
+	rtbl := buildAlgebra(map[string]string{"10.0.0.0/8": "a", "10.0.0.0/9": "b", "10.128.0.0/10": "c"})
+	checkRoutes(t, "Compress(nil)", rtbl.Compress(nil), []string{"10.0.0.0/8 b"})
+}
+
+// TestCompressRandom compares the lookup results of the compressed and the
+// original table for random addresses.
+func TestCompressRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		rtbl := randomTable(random, 2_000, 4)
+
+		fib := rtbl.Compress(func(a, b int) bool { return a == b })
+		if fib.Size() >= rtbl.Size() {
+			t.Fatalf("Compress() - expected less than %d routes, got %d", rtbl.Size(), fib.Size())
+		}
+		checkRandomLookups(t, "Compress()", random, 10_000, lookupOf(fib), lookupOf(rtbl))
+	}
+}
+
+// BenchmarkCompress with long IPv6 prefixes, deep in the binary trie.
+func BenchmarkCompress(b *testing.B) {
+	random := rand.New(rand.NewSource(42))
+	rtbl := ipcritbit.New[int]()
+	rtbl.Add(netip.MustParsePrefix("::/0"), 0)
+	for rtbl.Size() < 200_000 {
+		var a16 [16]byte
+		random.Read(a16[:6])
+		rtbl.Add(netip.PrefixFrom(netip.AddrFrom16(a16), 48), random.Intn(8))
+	}
+	equal := func(a, b int) bool { return a == b }
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rtbl.Compress(equal)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"math/bits"
 	"os"
 	"strconv"
 )
@@ -26,37 +27,57 @@ func init() {
 	buildMsbMatrix()
 }
 
-type node struct {
-	internal *internal
-	external *external
+type node[V any] struct {
+	internal *internal[V]
+	external *external[V]
 }
 
-type internal struct {
-	child  [2]node
+type internal[V any] struct {
+	child  [2]node[V]
 	offset int
 	bit    byte
 	cont   bool // if true, key of child[1] contains key of child[0]
 }
 
-type external struct {
-	key   []byte
-	value interface{}
+// maxKeyLen is the longest key, an IPv6 address followed by the mask, see pfxToKey.
+const maxKeyLen = 17
+
+// keys are copied into a fixed size array, no extra allocation per node.
+type external[V any] struct {
+	kbuf  [maxKeyLen]byte
+	klen  uint8
+	value V
+}
+
+func newExternal[V any](key []byte, value V) *external[V] {
+	if len(key) > maxKeyLen {
+		panic("key too long")
+	}
+	n := &external[V]{klen: uint8(len(key)), value: value}
+	copy(n.kbuf[:], key)
+	return n
 }
 
-// critBitTree
-type critBitTree struct {
-	root  node
+// key returns the stored key, the slice aliases the node.
+func (n *external[V]) key() []byte {
+	return n.kbuf[:n.klen]
+}
+
+// critBitTree, generic over the value type V.
+type critBitTree[V any] struct {
+	root  node[V]
 	items int
 }
 
 // create a tree.
-func newTree() *critBitTree {
-	return &critBitTree{}
+func newTree[V any]() *critBitTree[V] {
+	return &critBitTree[V]{}
 }
 
 // finding the critical bit.
-func (n *external) criticalBit(key []byte) (offset int, bit byte, cont bool) {
-	nlen := len(n.key)
+func (n *external[V]) criticalBit(key []byte) (offset int, bit byte, cont bool) {
+	nkey := n.key()
+	nlen := len(nkey)
 	klen := len(key)
 	mlen := nlen
 	if nlen > klen {
@@ -65,7 +86,7 @@ func (n *external) criticalBit(key []byte) (offset int, bit byte, cont bool) {
 
 	// find first differing byte and bit
 	for offset = 0; offset < mlen; offset++ {
-		if a, b := key[offset], n.key[offset]; a != b {
+		if a, b := key[offset], nkey[offset]; a != b {
 			bit = msbMatrix[a^b]
 			return
 		}
@@ -74,7 +95,7 @@ func (n *external) criticalBit(key []byte) (offset int, bit byte, cont bool) {
 	if nlen < klen {
 		bit = msbMatrix[key[offset]]
 	} else if nlen > klen {
-		bit = msbMatrix[n.key[offset]]
+		bit = msbMatrix[nkey[offset]]
 	} else {
 		// two keys are equal
 		offset = -1
@@ -83,7 +104,7 @@ func (n *external) criticalBit(key []byte) (offset int, bit byte, cont bool) {
 }
 
 // calculate direction.
-func (n *internal) direction(key []byte) int {
+func (n *internal[V]) direction(key []byte) int {
 	if n.offset < len(key) && (key[n.offset]&n.bit != 0 || n.cont) {
 		return 1
 	}
@@ -91,7 +112,7 @@ func (n *internal) direction(key []byte) int {
 }
 
 // searching the tree.
-func (t *critBitTree) search(key []byte) *node {
+func (t *critBitTree[V]) search(key []byte) *node[V] {
 	n := &t.root
 	for n.internal != nil {
 		n = &n.internal.child[n.internal.direction(key)]
@@ -100,8 +121,8 @@ func (t *critBitTree) search(key []byte) *node {
 }
 
 // membership testing.
-func (t *critBitTree) contains(key []byte) bool {
-	if n := t.search(key); n.external != nil && bytes.Equal(n.external.key, key) {
+func (t *critBitTree[V]) contains(key []byte) bool {
+	if n := t.search(key); n.external != nil && bytes.Equal(n.external.key(), key) {
 		return true
 	}
 	return false
@@ -109,21 +130,18 @@ func (t *critBitTree) contains(key []byte) bool {
 
 // get member.
 // if `key` is in Trie, `ok` is true.
-func (t *critBitTree) get(key []byte) (value interface{}, ok bool) {
-	if n := t.search(key); n.external != nil && bytes.Equal(n.external.key, key) {
+func (t *critBitTree[V]) get(key []byte) (value V, ok bool) {
+	if n := t.search(key); n.external != nil && bytes.Equal(n.external.key(), key) {
 		return n.external.value, true
 	}
 	return
 }
 
 // insertHelper into the tree (replaceable).
-func (t *critBitTree) insertHelper(key []byte, value interface{}, replace bool) bool {
+func (t *critBitTree[V]) insertHelper(key []byte, value V, replace bool) bool {
 	// an empty tree
 	if t.items == 0 {
-		t.root.external = &external{
-			key:   key,
-			value: value,
-		}
+		t.root.external = newExternal(key, value)
 		t.items = 1
 		return true
 	}
@@ -141,16 +159,13 @@ func (t *critBitTree) insertHelper(key []byte, value interface{}, replace bool)
 	}
 
 	// allocate new node
-	newNode := &internal{
+	newNode := &internal[V]{
 		offset: newOffset,
 		bit:    newBit,
 		cont:   newCont,
 	}
 	direction := newNode.direction(key)
-	newNode.child[direction].external = &external{
-		key:   key,
-		value: value,
-	}
+	newNode.child[direction].external = newExternal(key, value)
 
 	// insert new node
 	wherep := &t.root
@@ -174,26 +189,26 @@ func (t *critBitTree) insertHelper(key []byte, value interface{}, replace bool)
 
 // insert into the tree.
 // if `key` is alredy in Trie, return false.
-func (t *critBitTree) insert(key []byte, value interface{}) bool {
+func (t *critBitTree[V]) insert(key []byte, value V) bool {
 	return t.insertHelper(key, value, false)
 }
 
 // set into the tree.
-func (t *critBitTree) set(key []byte, value interface{}) {
+func (t *critBitTree[V]) set(key []byte, value V) {
 	t.insertHelper(key, value, true)
 }
 
 // deleting elements.
 // if `key` is in Trie, `ok` is true.
-func (t *critBitTree) delete(key []byte) (value interface{}, ok bool) {
+func (t *critBitTree[V]) delete(key []byte) (value V, ok bool) {
 	// an empty tree
 	if t.items == 0 {
 		return
 	}
 
 	var direction int
-	var whereq *node // pointer to the grandparent
-	var wherep *node = &t.root
+	var whereq *node[V] // pointer to the grandparent
+	var wherep *node[V] = &t.root
 
 	// finding the best candidate to delete
 	for in := wherep.internal; in != nil; in = wherep.internal {
@@ -203,7 +218,7 @@ func (t *critBitTree) delete(key []byte) (value interface{}, ok bool) {
 	}
 
 	// checking that we have the right element
-	if !bytes.Equal(wherep.external.key, key) {
+	if !bytes.Equal(wherep.external.key(), key) {
 		return
 	}
 	value = wherep.external.value
@@ -221,28 +236,137 @@ func (t *critBitTree) delete(key []byte) (value interface{}, ok bool) {
 	return
 }
 
+// persistent set, the tree is not modified.
+// The internal nodes on the path to key are copied, all other nodes are
+// shared between both trees.
+func (t *critBitTree[V]) setPersist(key []byte, value V) *critBitTree[V] {
+	nt := &critBitTree[V]{root: t.root, items: t.items}
+
+	// an empty tree
+	if t.items == 0 {
+		nt.root = node[V]{external: newExternal(key, value)}
+		nt.items = 1
+		return nt
+	}
+
+	newOffset, newBit, newCont := t.search(key).external.criticalBit(key)
+
+	// already exists in the tree, replace the leaf
+	if newOffset == -1 {
+		wherep := nt.clonePath(key, func(*internal[V]) bool { return false })
+		wherep.external = newExternal(key, value)
+		return nt
+	}
+
+	newNode := &internal[V]{
+		offset: newOffset,
+		bit:    newBit,
+		cont:   newCont,
+	}
+	direction := newNode.direction(key)
+	newNode.child[direction].external = newExternal(key, value)
+
+	wherep := nt.clonePath(key, func(in *internal[V]) bool {
+		return in.offset > newOffset || (in.offset == newOffset && in.bit < newBit)
+	})
+	newNode.child[1-direction] = *wherep
+	*wherep = node[V]{internal: newNode}
+	nt.items += 1
+	return nt
+}
+
+// persistent delete, the tree is not modified.
+// if `key` is in Trie, `ok` is true and a new tree is returned, else t.
+func (t *critBitTree[V]) deletePersist(key []byte) (nt *critBitTree[V], value V, ok bool) {
+	if t.items == 0 || !t.contains(key) {
+		return t, value, false
+	}
+	nt = &critBitTree[V]{root: t.root, items: t.items}
+
+	var direction int
+	var whereq *node[V] // pointer to the grandparent
+	var wherep *node[V] = &nt.root
+
+	for in := wherep.internal; in != nil; in = wherep.internal {
+		c := *in
+		wherep.internal = &c
+		direction = c.direction(key)
+		whereq = wherep
+		wherep = &c.child[direction]
+	}
+	value = wherep.external.value
+
+	if whereq == nil {
+		nt.root = node[V]{}
+	} else {
+		*whereq = whereq.internal.child[1-direction]
+	}
+	nt.items -= 1
+	return nt, value, true
+}
+
+// clonePath copies the internal nodes from the root in direction of key,
+// until stop returns true or a leaf is reached. The returned node belongs
+// to copied nodes only and may be modified.
+func (t *critBitTree[V]) clonePath(key []byte, stop func(*internal[V]) bool) *node[V] {
+	wherep := &t.root
+	for in := wherep.internal; in != nil && !stop(in); in = wherep.internal {
+		c := *in
+		wherep.internal = &c
+		wherep = &c.child[c.direction(key)]
+	}
+	return wherep
+}
+
+// deep copy of the tree, values are copied by cp.
+func (t *critBitTree[V]) clone(cp func(V) V) *critBitTree[V] {
+	nt := &critBitTree[V]{items: t.items}
+	if t.items > 0 {
+		cloneHelper(&nt.root, &t.root, cp)
+	}
+	return nt
+}
+
+func cloneHelper[V any](dst, src *node[V], cp func(V) V) {
+	if in := src.internal; in != nil {
+		c := &internal[V]{
+			offset: in.offset,
+			bit:    in.bit,
+			cont:   in.cont,
+		}
+		for i := 0; i < 2; i++ {
+			cloneHelper(&c.child[i], &in.child[i], cp)
+		}
+		dst.internal = c
+		return
+	}
+	e := *src.external
+	e.value = cp(e.value)
+	dst.external = &e
+}
+
 // clearing a tree.
-func (t *critBitTree) clear() {
+func (t *critBitTree[V]) clear() {
 	t.root.internal = nil
 	t.root.external = nil
 	t.items = 0
 }
 
 // return the number of key in a tree.
-func (t *critBitTree) size() int {
+func (t *critBitTree[V]) size() int {
 	return t.items
 }
 
 // Iterating elements from a given start key.
 // handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
-func (t *critBitTree) walk(handle func(key []byte, value interface{}) bool) bool {
+func (t *critBitTree[V]) walk(handle func(key []byte, value V) bool) bool {
 	if t.items == 0 {
 		return true
 	}
 	return walkHelper(&t.root, handle)
 }
 
-func walkHelper(n *node, handle func([]byte, interface{}) bool) bool {
+func walkHelper[V any](n *node[V], handle func([]byte, V) bool) bool {
 	if n.internal != nil {
 		var direction int
 		if !walkHelper(&n.internal.child[direction], handle) {
@@ -254,12 +378,57 @@ func walkHelper(n *node, handle func([]byte, interface{}) bool) bool {
 		}
 		return true
 	} else {
-		return handle(n.external.key, n.external.value)
+		return handle(n.external.key(), n.external.value)
+	}
+}
+
+// Iterating all elements whose keys share the first nbits bits with key.
+// The tree is descended while the critical bits are within the first nbits,
+// all keys below that node share the same leading bits (keys of equal length).
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *critBitTree[V]) walkPrefixed(key []byte, nbits int, handle func(key []byte, value V) bool) bool {
+	if t.items == 0 {
+		return true
+	}
+	n := &t.root
+	for n.internal != nil && n.internal.bitPos() < nbits {
+		n = &n.internal.child[n.internal.direction(key)]
 	}
+
+	// the path only compared the critical bits, check any leaf below n
+	leaf := n
+	for leaf.internal != nil {
+		leaf = &leaf.internal.child[0]
+	}
+	if !hasPrefix(leaf.external.key(), key, nbits) {
+		return true
+	}
+	return walkHelper(n, handle)
+}
+
+// position of the critical bit, counted from the msb of the first byte.
+func (n *internal[V]) bitPos() int {
+	return n.offset*8 + bits.LeadingZeros8(n.bit)
+}
+
+// hasPrefix reports whether the first nbits bits of a and b are equal.
+func hasPrefix(a, b []byte, nbits int) bool {
+	if len(a)*8 < nbits || len(b)*8 < nbits {
+		return false
+	}
+	div := nbits >> 3
+	if !bytes.Equal(a[:div], b[:div]) {
+		return false
+	}
+	if mod := uint(nbits & 0x07); mod > 0 {
+		mask := byte(0xff) << (8 - mod)
+		return a[div]&mask == b[div]&mask
+	}
+	return true
 }
 
 // dump tree. (for debugging)
-func (t *critBitTree) dump(w io.Writer) {
+func (t *critBitTree[V]) dump(w io.Writer) {
 	if t.root.internal == nil && t.root.external == nil {
 		return
 	}
@@ -269,7 +438,7 @@ func (t *critBitTree) dump(w io.Writer) {
 	dumpHelper(w, &t.root, true, "")
 }
 
-func dumpHelper(w io.Writer, n *node, right bool, prefix string) {
+func dumpHelper[V any](w io.Writer, n *node[V], right bool, prefix string) {
 	var ownprefix string
 	if right {
 		ownprefix = prefix
@@ -292,7 +461,7 @@ func dumpHelper(w io.Writer, n *node, right bool, prefix string) {
 			dumpHelper(w, &in.child[i], right, nextprefix)
 		}
 	} else {
-		fmt.Fprintf(w, "%s-- key=%d (%s)\n", ownprefix, n.external.key, key2str(n.external.key))
+		fmt.Fprintf(w, "%s-- key=%d (%s)\n", ownprefix, n.external.key(), key2str(n.external.key()))
 	}
 	return
 }
@@ -3,11 +3,12 @@ package ipcritbit
 import (
 	"bytes"
 	"math/rand"
+	"strings"
 	"testing"
 )
 
-func buildTrie(t *testing.T, keys []string) *critBitTree {
-	trie := newTree()
+func buildTrie(t *testing.T, keys []string) *critBitTree[any] {
+	trie := newTree[any]()
 	for _, key := range keys {
 		if !trie.insert([]byte(key), key) {
 			t.Errorf("insert() - failed insert \"%s\"\n%s", key, dumpTrie(trie))
@@ -16,7 +17,7 @@ func buildTrie(t *testing.T, keys []string) *critBitTree {
 	return trie
 }
 
-func dumpTrie(trie *critBitTree) string {
+func dumpTrie(trie *critBitTree[any]) string {
 	buf := bytes.NewBufferString("")
 	trie.dump(buf)
 	return buf.String()
@@ -159,7 +160,7 @@ func TestWalk(t *testing.T) {
 }
 
 func TestEmptyTree(t *testing.T) {
-	trie := newTree()
+	trie := newTree[any]()
 	key := []byte{0, 1, 2}
 	handle := func(_ []byte, _ interface{}) bool { return true }
 	assert := func(n string, f func()) {
@@ -176,3 +177,158 @@ func TestEmptyTree(t *testing.T) {
 	assert("delete", func() { trie.delete(key) })
 	assert("walk", func() { trie.walk(handle) })
 }
+
+func TestWalkPrefixed(t *testing.T) {
+	keys := []string{"aa", "ab", "ba", "bb", "bc", "ca"}
+	trie := buildTrie(t, keys)
+
+	tests := []struct {
+		key   string
+		nbits int
+		want  []string
+	}{
+		{"a", 8, []string{"aa", "ab"}},
+		{"b", 8, []string{"ba", "bb", "bc"}},
+		{"bb", 16, []string{"bb"}},
+		{"bb", 15, []string{"bb", "bc"}}, // 'b' = 0x62, 'c' = 0x63
+		{"x", 8, nil},
+		{"", 0, []string{"aa", "ab", "ba", "bb", "bc", "ca"}},
+	}
+	for _, tt := range tests {
+		var elems []string
+		trie.walkPrefixed([]byte(tt.key), tt.nbits, func(key []byte, _ any) bool {
+			elems = append(elems, string(key))
+			return true
+		})
+		if len(elems) != len(tt.want) {
+			t.Errorf("walkPrefixed(%q, %d) - expected %v, got %v", tt.key, tt.nbits, tt.want, elems)
+			continue
+		}
+		for i := range elems {
+			if elems[i] != tt.want[i] {
+				t.Errorf("walkPrefixed(%q, %d) - expected %v, got %v", tt.key, tt.nbits, tt.want, elems)
+				break
+			}
+		}
+	}
+}
+
+func TestDot(t *testing.T) {
+	keys := []string{"a", "aa", "b", "bb", "ab"}
+	trie := buildTrie(t, keys)
+
+	buf := bytes.NewBufferString("")
+	if err := trie.dot(buf); err != nil {
+		t.Fatalf("dot() - unexpected error: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "digraph critbit {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("dot() - not a digraph:\n%s", out)
+	}
+	// a critbit tree with n leaves has n-1 internal nodes, each with two edges
+	if n := strings.Count(out, " -> "); n != 2*(len(keys)-1) {
+		t.Errorf("dot() - expected %d edges, got %d:\n%s", 2*(len(keys)-1), n, out)
+	}
+	if n := strings.Count(out, "shape=box"); n != len(keys) {
+		t.Errorf("dot() - expected %d leaves, got %d:\n%s", len(keys), n, out)
+	}
+	for _, key := range keys {
+		if !strings.Contains(out, "label=\""+key+"\"") {
+			t.Errorf("dot() - missing leaf %q:\n%s", key, out)
+		}
+	}
+
+	buf.Reset()
+	if err := newTree[any]().dot(buf); err != nil || strings.Contains(buf.String(), "n0") {
+		t.Errorf("dot() - empty tree: %v\n%s", err, buf.String())
+	}
+}
+
+func TestPersist(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := buildTrie(t, keys)
+	dump := dumpTrie(trie)
+
+	// same shape as the mutable insert, original unchanged
+	ptrie := newTree[any]()
+	versions := []*critBitTree[any]{ptrie}
+	for _, key := range keys {
+		ptrie = ptrie.setPersist([]byte(key), key)
+		versions = append(versions, ptrie)
+	}
+	if pdump := dumpTrie(ptrie); pdump != dump {
+		t.Errorf("setPersist() - different tries\norigin:\n%s\nother:\n%s\n", dump, pdump)
+	}
+	for i, v := range versions {
+		if v.size() != i {
+			t.Errorf("setPersist() - version %d modified, size %d", i, v.size())
+		}
+		for j, key := range keys {
+			if v.contains([]byte(key)) != (j < i) {
+				t.Errorf("setPersist() - version %d modified, key %q", i, key)
+			}
+		}
+	}
+
+	// replace
+	replaced := ptrie.setPersist([]byte("a"), 100)
+	if v, _ := replaced.get([]byte("a")); v != 100 {
+		t.Errorf("setPersist() - failed replace - %v", v)
+	}
+	if v, _ := ptrie.get([]byte("a")); v != "a" {
+		t.Errorf("setPersist() - replace modified original - %v", v)
+	}
+	if replaced.size() != len(keys) {
+		t.Errorf("setPersist() - replace changed size - %d", replaced.size())
+	}
+
+	// delete, all versions unchanged
+	del := ptrie
+	for i, key := range keys {
+		var ok bool
+		var v any
+		if del, v, ok = del.deletePersist([]byte(key)); !ok || v != key {
+			t.Errorf("deletePersist() - failed - %s", key)
+		}
+		if del.contains([]byte(key)) || del.size() != len(keys)-i-1 {
+			t.Errorf("deletePersist() - exists - %s", key)
+		}
+		for _, key2 := range keys[i+1:] {
+			if !del.contains([]byte(key2)) {
+				t.Errorf("deletePersist() - other not exists - %s", key2)
+			}
+		}
+	}
+	if same, _, ok := del.deletePersist([]byte("a")); ok || same != del {
+		t.Error("deletePersist() - phantom delete")
+	}
+	if dumpTrie(ptrie) != dump {
+		t.Error("deletePersist() - original modified")
+	}
+}
+
+func TestClone(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := buildTrie(t, keys)
+	dump := dumpTrie(trie)
+
+	clone := trie.clone(func(v any) any { return v })
+	if cdump := dumpTrie(clone); cdump != dump {
+		t.Errorf("clone() - different tries\norigin:\n%s\nclone:\n%s\n", dump, cdump)
+	}
+
+	clone.delete([]byte("a"))
+	clone.set([]byte("b"), 100)
+	clone.insert([]byte("c"), nil)
+	if dumpTrie(trie) != dump {
+		t.Error("clone() - original modified")
+	}
+	if v, _ := trie.get([]byte("b")); v != "b" {
+		t.Errorf("clone() - original value modified: %v", v)
+	}
+
+	if empty := newTree[any]().clone(nil); empty.size() != 0 {
+		t.Error("clone() - empty tree")
+	}
+}
@@ -0,0 +1,97 @@
+package ipcritbit
+
+import (
+	"bytes"
+	"iter"
+	"net/netip"
+)
+
+// ChangeKind describes a route change reported by Diff.
+type ChangeKind int
+
+const (
+	// Added route, only in the new table.
+	Added ChangeKind = iota
+	// Removed route, only in the old table.
+	Removed
+	// Changed route, in both tables with different values.
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// Change of a route between two tables, OldValue is the zero value for
+// added routes, NewValue for removed routes.
+type Change[V any] struct {
+	Kind     ChangeKind
+	Prefix   netip.Prefix
+	OldValue V
+	NewValue V
+}
+
+// Diff returns the changes from old to new in canonical order, see Compare.
+// Both critbit trees are walked in parallel, values of prefixes in both
+// tables are compared with equal. If equal is nil, no changed routes are
+// reported.
+func Diff[V any](old, new RouteTable[V], equal func(a, b V) bool) []Change[V] {
+	changes := diffTree(nil, old.tree4, new.tree4, equal)
+	return diffTree(changes, old.tree6, new.tree6, equal)
+}
+
+func diffTree[V any](changes []Change[V], a, b *critBitTree[V], equal func(V, V) bool) []Change[V] {
+	if a == b {
+		return changes
+	}
+
+	nextA, stopA := iter.Pull2(a.keys())
+	defer stopA()
+	nextB, stopB := iter.Pull2(b.keys())
+	defer stopB()
+
+	ka, va, okA := nextA()
+	kb, vb, okB := nextB()
+	for okA || okB {
+		c := 0
+		switch {
+		case !okA:
+			c = 1
+		case !okB:
+			c = -1
+		default:
+			c = bytes.Compare(ka, kb)
+		}
+
+		switch {
+		case c < 0:
+			changes = append(changes, Change[V]{Kind: Removed, Prefix: keyToPfx(ka), OldValue: va})
+			ka, va, okA = nextA()
+		case c > 0:
+			changes = append(changes, Change[V]{Kind: Added, Prefix: keyToPfx(kb), NewValue: vb})
+			kb, vb, okB = nextB()
+		default:
+			if equal != nil && !equal(va, vb) {
+				changes = append(changes, Change[V]{Kind: Changed, Prefix: keyToPfx(ka), OldValue: va, NewValue: vb})
+			}
+			ka, va, okA = nextA()
+			kb, vb, okB = nextB()
+		}
+	}
+	return changes
+}
+
+// keys returns an iterator over the keys and values in the tree.
+func (t *critBitTree[V]) keys() iter.Seq2[[]byte, V] {
+	return func(yield func([]byte, V) bool) {
+		t.walk(yield)
+	}
+}
@@ -0,0 +1,74 @@
+package ipcritbit_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func TestDiff(t *testing.T) {
+	old := buildAlgebra(map[string]string{
+		"10.0.0.0/8":     "a",
+		"10.1.0.0/16":    "b",
+		"192.168.0.0/16": "c",
+		"2001:db8::/32":  "d",
+	})
+	new := buildAlgebra(map[string]string{
+		"10.0.0.0/8":    "a",
+		"10.1.0.0/16":   "B",
+		"172.16.0.0/12": "e",
+		"::/0":          "f",
+		"2001:db8::/32": "d",
+	})
+
+	var got []string
+	for _, c := range ipcritbit.Diff(old, new, func(a, b string) bool { return a == b }) {
+		got = append(got, fmt.Sprintf("%s %s %q %q", c.Kind, c.Prefix, c.OldValue, c.NewValue))
+	}
+	want := []string{
+		`changed 10.1.0.0/16 "b" "B"`,
+		`added 172.16.0.0/12 "" "e"`,
+		`removed 192.168.0.0/16 "c" ""`,
+		`added ::/0 "" "f"`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Diff() - expected:\n%v\ngot:\n%v", want, got)
+	}
+
+	if changes := ipcritbit.Diff(old, new, nil); len(changes) != 3 {
+		t.Errorf("Diff(nil) - expected 3 changes, got %v", changes)
+	}
+	if changes := ipcritbit.Diff(old, old, nil); len(changes) != 0 {
+		t.Errorf("Diff() - expected no changes, got %v", changes)
+	}
+	if changes := ipcritbit.Diff(ipcritbit.New[string](), ipcritbit.New[string](), nil); len(changes) != 0 {
+		t.Errorf("Diff() - expected no changes, got %v", changes)
+	}
+}
+
+// TestDiffRandom applies the changes to the old table, the result must be
+// the new table.
+func TestDiffRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		old, new := randomTable(random, 500, 3), randomTable(random, 500, 3)
+
+		changes := ipcritbit.Diff(old, new, func(a, b int) bool { return a == b })
+		for i, c := range changes {
+			if i > 0 && ipcritbit.Compare(changes[i-1].Prefix, c.Prefix) >= 0 {
+				t.Fatalf("Diff() - not in canonical order: %s, %s", changes[i-1].Prefix, c.Prefix)
+			}
+			switch c.Kind {
+			case ipcritbit.Added, ipcritbit.Changed:
+				old.Add(c.Prefix, c.NewValue)
+			case ipcritbit.Removed:
+				old.Delete(c.Prefix)
+			}
+		}
+		if old.String() != new.String() {
+			t.Fatalf("Diff() - applied changes differ from new table")
+		}
+	}
+}
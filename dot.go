@@ -0,0 +1,81 @@
+package ipcritbit
+
+import (
+	"fmt"
+	"io"
+)
+
+// dotWriter keeps the first write error, the DOT output is written in many
+// small pieces.
+type dotWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (dw *dotWriter) printf(format string, a ...any) {
+	if dw.err == nil {
+		_, dw.err = fmt.Fprintf(dw.w, format, a...)
+	}
+}
+
+// dot writes the tree in Graphviz DOT format. (for debugging)
+func (t *critBitTree[V]) dot(w io.Writer) error {
+	dw := &dotWriter{w: w}
+	dw.printf("digraph critbit {\n")
+	dw.printf("\tnode [fontname=monospace];\n")
+	t.dotNodes(dw, "n", key2str)
+	dw.printf("}\n")
+	return dw.err
+}
+
+// dotNodes writes the nodes and edges of the tree, the node ids start with
+// id. Internal nodes show offset, bit and cont, leaves the key formatted
+// by label.
+func (t *critBitTree[V]) dotNodes(dw *dotWriter, id string, label func([]byte) string) {
+	if t.items == 0 {
+		return
+	}
+	var seq int
+	dotHelper(dw, &t.root, id, &seq, label)
+}
+
+func dotHelper[V any](dw *dotWriter, n *node[V], id string, seq *int, label func([]byte) string) string {
+	name := fmt.Sprintf("%s%d", id, *seq)
+	*seq++
+
+	in := n.internal
+	if in == nil {
+		dw.printf("\t%s [shape=box, label=%q];\n", name, label(n.external.key()))
+		return name
+	}
+	dw.printf("\t%s [shape=ellipse, label=%q];\n", name,
+		fmt.Sprintf("off=%d bit=%08b(%02x) cont=%v", in.offset, in.bit, in.bit, in.cont))
+	for i := 0; i < 2; i++ {
+		child := dotHelper(dw, &in.child[i], id, seq, label)
+		dw.printf("\t%s -> %s [label=\"%d\"];\n", name, child, i)
+	}
+	return name
+}
+
+// DumpDOT writes the internal critbit nodes of the routing table in Graphviz
+// DOT format, IPv4 and IPv6 as separate clusters. (for debugging)
+//
+//	dot -Tsvg -o routes.svg routes.dot
+func (t RouteTable[V]) DumpDOT(w io.Writer) error {
+	dw := &dotWriter{w: w}
+	dw.printf("digraph routes {\n")
+	dw.printf("\tnode [fontname=monospace];\n")
+	for _, c := range []struct {
+		name string
+		tree *critBitTree[V]
+	}{{"4", t.tree4}, {"6", t.tree6}} {
+		dw.printf("\tsubgraph cluster_ipv%s {\n", c.name)
+		dw.printf("\tlabel=\"IPv%s (%d routes)\";\n", c.name, c.tree.items)
+		c.tree.dotNodes(dw, "v"+c.name+"_", func(key []byte) string {
+			return keyToPfx(key).String()
+		})
+		dw.printf("\t}\n")
+	}
+	dw.printf("}\n")
+	return dw.err
+}
@@ -0,0 +1,47 @@
+package ipcritbit_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDumpDOT(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	var sb strings.Builder
+	if err := rtbl.DumpDOT(&sb); err != nil {
+		t.Fatalf("DumpDOT() - unexpected error: %v", err)
+	}
+	out := sb.String()
+
+	for _, s := range []string{
+		"digraph routes {\n",
+		"subgraph cluster_ipv4 {",
+		"subgraph cluster_ipv6 {",
+		"label=\"IPv4 (11 routes)\"",
+		"label=\"IPv6 (4 routes)\"",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("DumpDOT() - missing %q:\n%s", s, out)
+		}
+	}
+
+	// each internal node has two edges, n leaves need n-1 internal nodes
+	if n := strings.Count(out, " -> "); n != 2*(11-1)+2*(4-1) {
+		t.Errorf("DumpDOT() - unexpected number of edges %d:\n%s", n, out)
+	}
+	for pfx := range rtbl.All() {
+		if !strings.Contains(out, "label=\""+pfx.String()+"\"") {
+			t.Errorf("DumpDOT() - missing leaf %s", pfx)
+		}
+	}
+
+	// node ids must be unique across the clusters
+	if !strings.Contains(out, "v4_0 ") || !strings.Contains(out, "v6_0 ") {
+		t.Errorf("DumpDOT() - missing node ids:\n%s", out)
+	}
+
+	if err := rtbl.DumpDOT(errWriter{}); err == nil {
+		t.Error("DumpDOT() - expected write error")
+	}
+}
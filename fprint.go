@@ -0,0 +1,92 @@
+package ipcritbit
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// pfxTree is a route with its direct subnets in canonical order, a node of
+// the CIDR containment tree.
+type pfxTree[V any] struct {
+	pfx  netip.Prefix
+	val  V
+	subs []*pfxTree[V]
+}
+
+// hierarchy builds the CIDR containment forest of all routes in the tree.
+// The walk is in canonical order, supernets before subnets, the stack holds
+// the chain of supernets of the current route.
+func (t *critBitTree[V]) hierarchy() []*pfxTree[V] {
+	var roots, stack []*pfxTree[V]
+	t.all(func(p netip.Prefix, v V) bool {
+		n := &pfxTree[V]{pfx: p, val: v}
+		for len(stack) > 0 && !covers(stack[len(stack)-1].pfx, p) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, n)
+		} else {
+			top := stack[len(stack)-1]
+			top.subs = append(top.subs, n)
+		}
+		stack = append(stack, n)
+		return true
+	})
+	return roots
+}
+
+// covers reports whether super contains p, both masked.
+func covers(super, p netip.Prefix) bool {
+	return super.Bits() <= p.Bits() && super.Contains(p.Addr())
+}
+
+// Fprint writes a hierarchical tree diagram of the routes to w, separately
+// for IPv4 and IPv6. Each route is listed below its nearest supernet, with
+// its value:
+//
+//	▼
+//	├─ 10.0.0.0/8 (v1)
+//	└─ 192.168.0.0/16 (v2)
+//	   └─ 192.168.1.0/24 (v3)
+//	      └─ 192.168.1.0/28 (v4)
+//
+// Dump prints the internal critbit nodes instead, for debugging.
+func (t RouteTable[V]) Fprint(w io.Writer) error {
+	for _, tree := range []*critBitTree[V]{t.tree4, t.tree6} {
+		if tree.items == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, "▼"); err != nil {
+			return err
+		}
+		if err := fprintRec(w, tree.hierarchy(), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fprintRec[V any](w io.Writer, nodes []*pfxTree[V], pad string) error {
+	for i, n := range nodes {
+		glyph, spacer := "├─ ", "│  "
+		if i == len(nodes)-1 {
+			glyph, spacer = "└─ ", "   "
+		}
+		if _, err := fmt.Fprintf(w, "%s%s%s (%v)\n", pad, glyph, n.pfx, n.val); err != nil {
+			return err
+		}
+		if err := fprintRec(w, n.subs, pad+spacer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the hierarchical tree diagram of the routes, see Fprint.
+func (t RouteTable[V]) String() string {
+	var sb strings.Builder
+	_ = t.Fprint(&sb)
+	return sb.String()
+}
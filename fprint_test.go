@@ -0,0 +1,68 @@
+package ipcritbit_test
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func TestFprint(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	want := `▼
+├─ 10.0.0.0/8 (10.0.0.0/8)
+└─ 192.168.0.0/16 (192.168.0.0/16)
+   ├─ 192.168.1.0/24 (192.168.1.0/24)
+   │  ├─ 192.168.1.0/28 (192.168.1.0/28)
+   │  │  ├─ 192.168.1.0/32 (192.168.1.0/32)
+   │  │  ├─ 192.168.1.1/32 (192.168.1.1/32)
+   │  │  └─ 192.168.1.2/32 (192.168.1.2/32)
+   │  └─ 192.168.1.32/27 (192.168.1.32/27)
+   │     └─ 192.168.1.32/30 (192.168.1.32/30)
+   ├─ 192.168.2.1/32 (192.168.2.1/32)
+   └─ 192.168.2.2/32 (192.168.2.2/32)
+▼
+└─ ::/0 (::/0)
+   ├─ 2001:db8::/32 (2001:db8::/32)
+   │  └─ 2001:db8::/64 (2001:db8::/64)
+   └─ fe80::/10 (fe80::/10)
+`
+
+	var sb strings.Builder
+	if err := rtbl.Fprint(&sb); err != nil {
+		t.Fatalf("Fprint() - unexpected error: %v", err)
+	}
+	if got := sb.String(); got != want {
+		t.Errorf("Fprint() - expected:\n%s\ngot:\n%s", want, got)
+	}
+	if got := rtbl.String(); got != want {
+		t.Errorf("String() - expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestFprintEmpty(t *testing.T) {
+	rtbl := ipcritbit.New[int]()
+	if got := rtbl.String(); got != "" {
+		t.Errorf("String() - empty table: %q", got)
+	}
+
+	rtbl.Add(netip.MustParsePrefix("2001:db8::/32"), 1)
+	want := "▼\n└─ 2001:db8::/32 (1)\n"
+	if got := rtbl.String(); got != want {
+		t.Errorf("String() - IPv6 only: expected %q, got %q", want, got)
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestFprintError(t *testing.T) {
+	rtbl := buildTestNetip(t)
+	if err := rtbl.Fprint(errWriter{}); err == nil {
+		t.Error("Fprint() - expected write error")
+	}
+}
@@ -0,0 +1,60 @@
+package ipcritbit
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// All returns an iterator over all routes in canonical order, see Compare.
+//
+//	for pfx, val := range rtbl.All() {
+//		...
+//	}
+func (t RouteTable[V]) All() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		t.Walk(yield)
+	}
+}
+
+// All4 returns an iterator over all IPv4 routes in canonical order.
+func (t RouteTable[V]) All4() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		t.Walk4(yield)
+	}
+}
+
+// All6 returns an iterator over all IPv6 routes in canonical order.
+func (t RouteTable[V]) All6() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		t.Walk6(yield)
+	}
+}
+
+// Supernets returns an iterator over all routes covering p, p itself
+// included, from the most to the least specific one, see WalkSupernets.
+// For an address use SupernetsIP.
+func (t RouteTable[V]) Supernets(p netip.Prefix) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		t.WalkSupernets(p, yield)
+	}
+}
+
+// SupernetsIP returns an iterator over all routes covering ip, from the
+// most to the least specific one, the iterator form of LookupAll.
+//
+//	for pfx, val := range rtbl.SupernetsIP(ip) {
+//		...
+//	}
+func (t RouteTable[V]) SupernetsIP(ip netip.Addr) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		t.LookupAll(ip, yield)
+	}
+}
+
+// Subnets returns an iterator over all routes covered by p, p itself
+// included, in canonical order, see WalkSubnets.
+func (t RouteTable[V]) Subnets(p netip.Prefix) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		t.WalkSubnets(p, yield)
+	}
+}
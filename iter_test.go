@@ -0,0 +1,115 @@
+package ipcritbit_test
+
+import (
+	"maps"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestIterAll(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	var got []string
+	for pfx, val := range rtbl.All() {
+		if pfx.String() != val {
+			t.Errorf("All() - value mismatch: %s, %s", pfx, val)
+		}
+		got = append(got, val)
+	}
+	if len(got) != rtbl.Size() {
+		t.Errorf("All() - expected %d routes, got %d", rtbl.Size(), len(got))
+	}
+
+	m := maps.Collect(rtbl.All())
+	if len(m) != rtbl.Size() {
+		t.Errorf("All() - maps.Collect: %d", len(m))
+	}
+
+	var n4, n6 int
+	for pfx := range rtbl.All4() {
+		if !pfx.Addr().Is4() {
+			t.Errorf("All4() - got %s", pfx)
+		}
+		n4++
+	}
+	for pfx := range rtbl.All6() {
+		if !pfx.Addr().Is6() {
+			t.Errorf("All6() - got %s", pfx)
+		}
+		n6++
+	}
+	if n4 != 11 || n6 != 4 {
+		t.Errorf("All4(), All6() - got %d, %d", n4, n6)
+	}
+}
+
+func TestIterAllBreak(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	// break on the last IPv4 route, IPv6 must not be visited
+	var n int
+	for pfx := range rtbl.All() {
+		if pfx.Addr().Is6() {
+			t.Fatalf("All() - break ignored, got %s", pfx)
+		}
+		n++
+		if n == 11 {
+			break
+		}
+	}
+
+	for range rtbl.All6() {
+		break
+	}
+}
+
+func TestIterSupernetsSubnets(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	pfx := netip.MustParsePrefix("192.168.1.32/30")
+	want := []string{"192.168.1.32/30", "192.168.1.32/27", "192.168.1.0/24", "192.168.0.0/16"}
+
+	var got []string
+	for _, val := range rtbl.Supernets(pfx) {
+		got = append(got, val)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Supernets(%s) - expected %v, got %v", pfx, want, got)
+	}
+
+	ip := netip.MustParseAddr("192.168.1.33")
+	want = []string{"192.168.1.32/30", "192.168.1.32/27", "192.168.1.0/24", "192.168.0.0/16"}
+
+	got = got[:0]
+	for _, val := range rtbl.SupernetsIP(ip) {
+		got = append(got, val)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("SupernetsIP(%s) - expected %v, got %v", ip, want, got)
+	}
+	for range rtbl.SupernetsIP(netip.Addr{}) {
+		t.Errorf("SupernetsIP() - invalid address")
+	}
+
+	pfx = netip.MustParsePrefix("192.168.1.0/26")
+	want = []string{"192.168.1.0/28", "192.168.1.0/32", "192.168.1.1/32", "192.168.1.2/32", "192.168.1.32/27", "192.168.1.32/30"}
+
+	got = got[:0]
+	for _, val := range rtbl.Subnets(pfx) {
+		got = append(got, val)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Subnets(%s) - expected %v, got %v", pfx, want, got)
+	}
+
+	for range rtbl.Subnets(pfx) {
+		break
+	}
+	for range rtbl.Supernets(pfx) {
+		break
+	}
+	for range rtbl.SupernetsIP(ip) {
+		break
+	}
+}
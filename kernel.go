@@ -0,0 +1,295 @@
+package ipcritbit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// KernelRoute is the value of a route loaded from the Linux kernel
+// routing table, see LoadProcNetRoute, LoadProcNetIPv6Route, LoadIPRouteJSON
+// and LoadIPv6RouteJSON. Of routes with the same prefix the loaders keep the one
+// with the lowest metric.
+type KernelRoute struct {
+	Gateway   netip.Addr // invalid for directly connected routes
+	Interface string
+	Metric    int
+}
+
+// route flags, see include/uapi/linux/route.h
+const (
+	rtfUp     = 0x0001
+	rtfReject = 0x0200
+	rtfLocal  = 0x80000000 // include/uapi/linux/ipv6_route.h
+)
+
+// parseProcFlags reports whether the route is usable, up and neither a
+// reject route like the null entry of /proc/net/ipv6_route nor a route of
+// the local table.
+func parseProcFlags(s string) (bool, error) {
+	flags, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return false, err
+	}
+	return flags&rtfUp != 0 && flags&(rtfReject|rtfLocal) == 0, nil
+}
+
+// LoadProcNetRoute adds the IPv4 routes in the format of /proc/net/route
+// to t. Addresses and masks are hex encoded in host byte order, the data
+// must be parsed on a host with the byte order of its origin. Routes not
+// up and reject routes are skipped.
+//
+//	Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask	...
+//	eth0	00000000	010200C0	0003	0	0	100	00000000	...
+func LoadProcNetRoute(t RouteTable[KernelRoute], r io.Reader) error {
+	return scanLines(r, "/proc/net/route", func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			return errors.New("too few fields")
+		}
+		if fields[0] == "Iface" {
+			// header
+			return nil
+		}
+
+		usable, err := parseProcFlags(fields[3])
+		if err != nil {
+			return err
+		}
+		if !usable {
+			return nil
+		}
+
+		dst, err := parseProcAddr4(fields[1])
+		if err != nil {
+			return err
+		}
+		gw, err := parseProcAddr4(fields[2])
+		if err != nil {
+			return err
+		}
+		mask, err := parseProcAddr4(fields[7])
+		if err != nil {
+			return err
+		}
+		metric, err := strconv.Atoi(fields[6])
+		if err != nil {
+			return err
+		}
+
+		a4 := mask.As4()
+		m := binary.BigEndian.Uint32(a4[:])
+		ones := bits.OnesCount32(m)
+		if m != ^uint32(0)<<(32-ones) {
+			return fmt.Errorf("non-contiguous mask %s", fields[7])
+		}
+
+		value := KernelRoute{Interface: fields[0], Metric: metric}
+		if !gw.IsUnspecified() {
+			value.Gateway = gw
+		}
+		return addKernelRoute(t, netip.PrefixFrom(dst, ones), value)
+	})
+}
+
+// addKernelRoute adds the route to t, of duplicate prefixes the route with
+// the lowest metric is kept, the route used by the kernel.
+func addKernelRoute(t RouteTable[KernelRoute], p netip.Prefix, value KernelRoute) error {
+	old, ok, err := t.TryGet(p)
+	if err != nil {
+		return err
+	}
+	if ok && old.Metric <= value.Metric {
+		return nil
+	}
+	return t.TryAdd(p, value)
+}
+
+// parseProcAddr4 parses an IPv4 address, hex encoded in host byte order.
+func parseProcAddr4(s string) (netip.Addr, error) {
+	u, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	var a4 [4]byte
+	binary.NativeEndian.PutUint32(a4[:], uint32(u))
+	return netip.AddrFrom4(a4), nil
+}
+
+// LoadProcNetIPv6Route adds the IPv6 routes in the format of
+// /proc/net/ipv6_route to t, all fields hex encoded in network byte order.
+// Routes not up, reject routes and the local and multicast routes of the
+// local table are skipped, like by LoadIPv6RouteJSON.
+//
+//	destination plen source plen next-hop metric refcnt use flags device
+func LoadProcNetIPv6Route(t RouteTable[KernelRoute], r io.Reader) error {
+	return scanLines(r, "/proc/net/ipv6_route", func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			return errors.New("too few fields")
+		}
+
+		usable, err := parseProcFlags(fields[8])
+		if err != nil {
+			return err
+		}
+		if !usable {
+			return nil
+		}
+
+		dst, err := parseProcAddr6(fields[0])
+		if err != nil {
+			return err
+		}
+		if dst.IsMulticast() {
+			// ff00::/8 of the local table
+			return nil
+		}
+		plen, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil {
+			return err
+		}
+		gw, err := parseProcAddr6(fields[4])
+		if err != nil {
+			return err
+		}
+		metric, err := strconv.ParseUint(fields[5], 16, 32)
+		if err != nil {
+			return err
+		}
+
+		value := KernelRoute{Interface: fields[9], Metric: int(metric)}
+		if !gw.IsUnspecified() {
+			value.Gateway = gw
+		}
+		return addKernelRoute(t, netip.PrefixFrom(dst, int(plen)), value)
+	})
+}
+
+func parseProcAddr6(s string) (netip.Addr, error) {
+	var a16 [16]byte
+	if len(s) != 2*len(a16) {
+		return netip.Addr{}, fmt.Errorf("bad IPv6 address %q", s)
+	}
+	if _, err := hex.Decode(a16[:], []byte(s)); err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.AddrFrom16(a16), nil
+}
+
+// scanLines calls parse for each non-empty line, errors are annotated with
+// the source name and line number.
+func scanLines(r io.Reader, name string, parse func(line string) error) error {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return fmt.Errorf("ipcritbit: %s line %d: %w", name, n, err)
+		}
+	}
+	return s.Err()
+}
+
+// ipRoute is an entry of `ip -j route`.
+type ipRoute struct {
+	Type     string `json:"type"`
+	Dst      string `json:"dst"`
+	Gateway  string `json:"gateway"`
+	Dev      string `json:"dev"`
+	Prefsrc  string `json:"prefsrc"`
+	Metric   int    `json:"metric"`
+	Nexthops []struct {
+		Gateway string `json:"gateway"`
+		Dev     string `json:"dev"`
+	} `json:"nexthops"`
+}
+
+// LoadIPRouteJSON adds the IPv4 routes in the format of `ip -j route` to t,
+// the destination "default" is 0.0.0.0/0. Of multipath routes the first
+// next hop is stored. Routes of a type other than unicast, e.g. unreachable,
+// blackhole or local, are skipped.
+func LoadIPRouteJSON(t RouteTable[KernelRoute], r io.Reader) error {
+	return loadIPRouteJSON(t, r, false)
+}
+
+// LoadIPv6RouteJSON is like LoadIPRouteJSON for the IPv6 routes in the
+// format of `ip -j -6 route`, the destination "default" is ::/0.
+// The JSON carries no address family, it must be given by the caller.
+func LoadIPv6RouteJSON(t RouteTable[KernelRoute], r io.Reader) error {
+	return loadIPRouteJSON(t, r, true)
+}
+
+func loadIPRouteJSON(t RouteTable[KernelRoute], r io.Reader, is6 bool) error {
+	var routes []ipRoute
+	if err := json.NewDecoder(r).Decode(&routes); err != nil {
+		return fmt.Errorf("ipcritbit: ip route json: %w", err)
+	}
+
+	for i, rt := range routes {
+		if rt.Type != "" && rt.Type != "unicast" {
+			// unreachable, blackhole, prohibit, local, broadcast, ...
+			continue
+		}
+		if len(rt.Nexthops) > 0 && rt.Gateway == "" && rt.Dev == "" {
+			rt.Gateway, rt.Dev = rt.Nexthops[0].Gateway, rt.Nexthops[0].Dev
+		}
+
+		value := KernelRoute{Interface: rt.Dev, Metric: rt.Metric}
+		if rt.Gateway != "" {
+			gw, err := netip.ParseAddr(rt.Gateway)
+			if err != nil {
+				return fmt.Errorf("ipcritbit: ip route json entry %d: %w", i, err)
+			}
+			value.Gateway = gw
+		}
+
+		p, err := parseIPRouteDst(rt.Dst, is6)
+		if err != nil {
+			return fmt.Errorf("ipcritbit: ip route json entry %d: %w", i, err)
+		}
+		if err := addKernelRoute(t, p, value); err != nil {
+			return fmt.Errorf("ipcritbit: ip route json entry %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// parseIPRouteDst parses "default", "10.0.0.0/8" or the host route "10.0.0.1",
+// the address family must match is6.
+func parseIPRouteDst(dst string, is6 bool) (netip.Prefix, error) {
+	if dst == "default" {
+		if is6 {
+			return netip.PrefixFrom(netip.IPv6Unspecified(), 0), nil
+		}
+		return netip.PrefixFrom(netip.IPv4Unspecified(), 0), nil
+	}
+
+	var p netip.Prefix
+	if strings.Contains(dst, "/") {
+		var err error
+		if p, err = netip.ParsePrefix(dst); err != nil {
+			return p, err
+		}
+	} else {
+		a, err := netip.ParseAddr(dst)
+		if err != nil {
+			return p, err
+		}
+		p = netip.PrefixFrom(a, a.BitLen())
+	}
+	if p.Addr().Is6() != is6 {
+		return p, fmt.Errorf("address family mismatch %s", dst)
+	}
+	return p, nil
+}
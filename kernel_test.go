@@ -0,0 +1,190 @@
+package ipcritbit_test
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func loadFixture(t *testing.T, name string, load func(ipcritbit.RouteTable[ipcritbit.KernelRoute], *os.File) error) ipcritbit.RouteTable[ipcritbit.KernelRoute] {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rtbl := ipcritbit.New[ipcritbit.KernelRoute]()
+	if err := load(rtbl, f); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return rtbl
+}
+
+func checkKernelRoute(t *testing.T, rtbl ipcritbit.RouteTable[ipcritbit.KernelRoute], probe, route string, want ipcritbit.KernelRoute) {
+	t.Helper()
+	r, v, ok := rtbl.Lookup(netip.MustParseAddr(probe))
+	if !ok || r.String() != route {
+		t.Errorf("Lookup(%s) - expected [%s], actual [%s]", probe, route, r)
+	}
+	if v != want {
+		t.Errorf("Lookup(%s) - expected %+v, actual %+v", probe, want, v)
+	}
+}
+
+func TestLoadProcNetRoute(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("fixture is in little endian byte order")
+	}
+	rtbl := loadFixture(t, "proc_net_route", func(rtbl ipcritbit.RouteTable[ipcritbit.KernelRoute], f *os.File) error {
+		return ipcritbit.LoadProcNetRoute(rtbl, f)
+	})
+
+	if rtbl.Size() != 4 {
+		t.Errorf("LoadProcNetRoute() - expected 4 routes, got %d", rtbl.Size())
+	}
+	gw := netip.MustParseAddr("192.0.2.1")
+	checkKernelRoute(t, rtbl, "8.8.8.8", "0.0.0.0/0", ipcritbit.KernelRoute{Gateway: gw, Interface: "eth0", Metric: 100})
+	checkKernelRoute(t, rtbl, "192.0.2.9", "192.0.2.0/24", ipcritbit.KernelRoute{Interface: "eth0", Metric: 100})
+	checkKernelRoute(t, rtbl, "10.1.2.3", "10.0.0.0/8", ipcritbit.KernelRoute{Interface: "wg0"})
+	checkKernelRoute(t, rtbl, "192.168.31.1", "192.168.16.0/20", ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("192.0.2.254"), Interface: "wg0", Metric: 50})
+
+	// duplicate prefixes, the lowest metric wins
+	dup := ipcritbit.New[ipcritbit.KernelRoute]()
+	in := "wlan0\t00000000\t010200C0\t0003\t0\t0\t100\t00000000\n" +
+		"eth1\t00000000\t016433C6\t0003\t0\t0\t600\t00000000\n" +
+		"eth2\t00000000\t017100CB\t0003\t0\t0\t50\t00000000\n" +
+		"eth3\t00000000\t027100CB\t0003\t0\t0\t50\t00000000\n"
+	if err := ipcritbit.LoadProcNetRoute(dup, strings.NewReader(in)); err != nil {
+		t.Fatalf("LoadProcNetRoute() - unexpected error: %v", err)
+	}
+	checkKernelRoute(t, dup, "8.8.8.8", "0.0.0.0/0", ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("203.0.113.1"), Interface: "eth2", Metric: 50})
+
+	// reject route and route not up
+	in = "lo\t0000000A\t00000000\t0201\t0\t0\t0\t000000FF\n" +
+		"eth1\t0000000B\t00000000\t0000\t0\t0\t0\t000000FF\n"
+	if err := ipcritbit.LoadProcNetRoute(rtbl, strings.NewReader(in)); err != nil {
+		t.Fatalf("LoadProcNetRoute() - unexpected error: %v", err)
+	}
+	if rtbl.Size() != 4 {
+		t.Errorf("LoadProcNetRoute() - unusable routes loaded: %d", rtbl.Size())
+	}
+
+	for name, in := range map[string]string{
+		"short":          "eth0\t00000000\n",
+		"bad address":    "eth0\tXX000000\t00000000\t0001\t0\t0\t0\t00000000\n",
+		"bad metric":     "eth0\t00000000\t00000000\t0001\t0\t0\tX\t00000000\n",
+		"non-contiguous": "eth0\t00000000\t00000000\t0001\t0\t0\t0\t00FF00FF\n",
+		"bad flags":      "eth0\t00000000\t00000000\tX\t0\t0\t0\t00000000\n",
+	} {
+		err := ipcritbit.LoadProcNetRoute(ipcritbit.New[ipcritbit.KernelRoute](), strings.NewReader(in))
+		if err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("LoadProcNetRoute() - %s: expected error in line 1, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadProcNetIPv6Route(t *testing.T) {
+	rtbl := loadFixture(t, "proc_net_ipv6_route", func(rtbl ipcritbit.RouteTable[ipcritbit.KernelRoute], f *os.File) error {
+		return ipcritbit.LoadProcNetIPv6Route(rtbl, f)
+	})
+
+	if rtbl.Size() != 3 {
+		t.Errorf("LoadProcNetIPv6Route() - expected 3 routes, got %d", rtbl.Size())
+	}
+	checkKernelRoute(t, rtbl, "2001:db8::1", "::/0", ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("fd00::1"), Interface: "eth0", Metric: 1024})
+	checkKernelRoute(t, rtbl, "fd00::5", "fd00::/64", ipcritbit.KernelRoute{Interface: "eth0", Metric: 256})
+	checkKernelRoute(t, rtbl, "fd00::2", "fd00::/64", ipcritbit.KernelRoute{Interface: "eth0", Metric: 256})
+	for _, pfx := range []string{"::1/128", "fd00::2/128", "ff00::/8"} {
+		if _, ok := rtbl.Get(netip.MustParsePrefix(pfx)); ok {
+			t.Errorf("LoadProcNetIPv6Route() - local table route %s loaded", pfx)
+		}
+	}
+
+	for name, in := range map[string]string{
+		"bad flags":   "fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 X eth0\n",
+		"short":       "fd000000000000000000000000000000 40\n",
+		"bad address": "fd00 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001 eth0\n",
+		"bad plen":    "fd000000000000000000000000000000 XX 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001 eth0\n",
+		"plen > 128":  "fd000000000000000000000000000000 81 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001 eth0\n",
+	} {
+		err := ipcritbit.LoadProcNetIPv6Route(ipcritbit.New[ipcritbit.KernelRoute](), strings.NewReader(in))
+		if err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("LoadProcNetIPv6Route() - %s: expected error in line 1, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadIPRouteJSON(t *testing.T) {
+	load := func(rtbl ipcritbit.RouteTable[ipcritbit.KernelRoute], f *os.File) error {
+		return ipcritbit.LoadIPRouteJSON(rtbl, f)
+	}
+
+	rtbl := loadFixture(t, "ip_route.json", load)
+	if rtbl.Size() != 4 {
+		t.Errorf("LoadIPRouteJSON() - expected 4 routes, got %d", rtbl.Size())
+	}
+	checkKernelRoute(t, rtbl, "8.8.8.8", "0.0.0.0/0", ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("192.0.2.1"), Interface: "eth0", Metric: 100})
+	checkKernelRoute(t, rtbl, "192.0.2.7", "192.0.2.0/24", ipcritbit.KernelRoute{Interface: "eth0", Metric: 100})
+	checkKernelRoute(t, rtbl, "10.0.0.1", "10.0.0.0/8", ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("192.0.2.253"), Interface: "eth0"})
+	checkKernelRoute(t, rtbl, "198.51.100.7", "198.51.100.7/32", ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("192.0.2.9"), Interface: "eth0"})
+
+	// non-unicast routes are skipped, duplicates keep the lowest metric
+	in := `[{"type":"unreachable","dst":"10.0.0.0/8","metric":1},
+		{"type":"blackhole","dst":"10.1.0.0/16"},
+		{"type":"local","dst":"127.0.0.1","dev":"lo"},
+		{"dst":"default","gateway":"192.0.2.1","dev":"wlan0","metric":100},
+		{"dst":"default","gateway":"198.51.100.1","dev":"eth1","metric":600},
+		{"type":"unicast","dst":"10.0.0.0/8","dev":"wg0","metric":5}]`
+	rtbl = ipcritbit.New[ipcritbit.KernelRoute]()
+	if err := ipcritbit.LoadIPRouteJSON(rtbl, strings.NewReader(in)); err != nil {
+		t.Fatalf("LoadIPRouteJSON() - unexpected error: %v", err)
+	}
+	if rtbl.Size() != 2 {
+		t.Errorf("LoadIPRouteJSON() - expected 2 routes, got %d", rtbl.Size())
+	}
+	checkKernelRoute(t, rtbl, "8.8.8.8", "0.0.0.0/0", ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("192.0.2.1"), Interface: "wlan0", Metric: 100})
+	checkKernelRoute(t, rtbl, "10.1.2.3", "10.0.0.0/8", ipcritbit.KernelRoute{Interface: "wg0", Metric: 5})
+
+	for name, in := range map[string]string{
+		"no json":     "dst default",
+		"bad dst":     `[{"dst":"10.0.0.0/33"}]`,
+		"bad host":    `[{"dst":"10.0.0.x"}]`,
+		"bad gateway": `[{"dst":"default","gateway":"x"}]`,
+		"ipv6 dst":    `[{"dst":"fd00::/64","dev":"eth0"}]`,
+		"ipv6 host":   `[{"dst":"fd00::1","dev":"eth0"}]`,
+	} {
+		if err := ipcritbit.LoadIPRouteJSON(ipcritbit.New[ipcritbit.KernelRoute](), strings.NewReader(in)); err == nil {
+			t.Errorf("LoadIPRouteJSON() - %s: expected error", name)
+		}
+	}
+}
+
+func TestLoadIPv6RouteJSON(t *testing.T) {
+	rtbl := loadFixture(t, "ip6_route.json", func(rtbl ipcritbit.RouteTable[ipcritbit.KernelRoute], f *os.File) error {
+		return ipcritbit.LoadIPv6RouteJSON(rtbl, f)
+	})
+	if rtbl.Size() != 3 {
+		t.Errorf("LoadIPv6RouteJSON() - expected 3 routes, got %d", rtbl.Size())
+	}
+	checkKernelRoute(t, rtbl, "2001:db8::1", "::/0", ipcritbit.KernelRoute{Gateway: netip.MustParseAddr("fd00::1"), Interface: "eth0", Metric: 1024})
+	checkKernelRoute(t, rtbl, "fe80::1", "fe80::/64", ipcritbit.KernelRoute{Interface: "eth0", Metric: 256})
+
+	// device only default route, no address in the entry
+	rtbl = ipcritbit.New[ipcritbit.KernelRoute]()
+	if err := ipcritbit.LoadIPv6RouteJSON(rtbl, strings.NewReader(`[{"dst":"default","dev":"wg0","metric":1024}]`)); err != nil {
+		t.Fatalf("LoadIPv6RouteJSON() - unexpected error: %v", err)
+	}
+	checkKernelRoute(t, rtbl, "2001:db8::1", "::/0", ipcritbit.KernelRoute{Interface: "wg0", Metric: 1024})
+	if rtbl.Contains(netip.MustParseAddr("192.0.2.1")) {
+		t.Errorf("LoadIPv6RouteJSON() - default route stored as IPv4")
+	}
+
+	if err := ipcritbit.LoadIPv6RouteJSON(rtbl, strings.NewReader(`[{"dst":"10.0.0.0/8","dev":"eth0"}]`)); err == nil {
+		t.Errorf("LoadIPv6RouteJSON() - ipv4 dst: expected error")
+	}
+}
@@ -0,0 +1,132 @@
+package ipcritbit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+)
+
+var (
+	_ json.Marshaler         = RouteTable[any]{}
+	_ json.Unmarshaler       = (*RouteTable[any])(nil)
+	_ encoding.TextMarshaler = RouteTable[any]{}
+)
+
+// DumpListNode is a route with its direct subnets, a node of the CIDR
+// containment tree, see DumpList.
+type DumpListNode[V any] struct {
+	CIDR    netip.Prefix      `json:"cidr"`
+	Value   V                 `json:"value"`
+	Subnets []DumpListNode[V] `json:"subnets,omitempty"`
+}
+
+// DumpList returns the routes as CIDR containment tree, IPv4 before IPv6,
+// each level in canonical order. The nested JSON form of the table is
+//
+//	json.Marshal(t.DumpList())
+func (t RouteTable[V]) DumpList() []DumpListNode[V] {
+	t = t.orEmpty()
+	var list []DumpListNode[V]
+	for _, tree := range []*critBitTree[V]{t.tree4, t.tree6} {
+		list = append(list, dumpListRec(tree.hierarchy())...)
+	}
+	return list
+}
+
+func dumpListRec[V any](nodes []*pfxTree[V]) []DumpListNode[V] {
+	if len(nodes) == 0 {
+		return nil
+	}
+	list := make([]DumpListNode[V], 0, len(nodes))
+	for _, n := range nodes {
+		list = append(list, DumpListNode[V]{
+			CIDR:    n.pfx,
+			Value:   n.val,
+			Subnets: dumpListRec(n.subs),
+		})
+	}
+	return list
+}
+
+// MarshalJSON implements json.Marshaler, the routes as flat list in
+// canonical order:
+//
+//	[{"cidr":"10.0.0.0/8","value":...},{"cidr":"10.1.0.0/16","value":...}]
+func (t RouteTable[V]) MarshalJSON() ([]byte, error) {
+	t = t.orEmpty()
+	list := make([]DumpListNode[V], 0, t.Size())
+	t.Walk(func(p netip.Prefix, value V) bool {
+		list = append(list, DumpListNode[V]{CIDR: p, Value: value})
+		return true
+	})
+	return json.Marshal(list)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the routes of t are replaced.
+// Both the flat and the nested form are accepted.
+// On error t is left unchanged.
+func (t *RouteTable[V]) UnmarshalJSON(data []byte) error {
+	var list []DumpListNode[V]
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	nt := New[V]()
+	nt.strict = t.strict
+	if err := nt.addList(list); err != nil {
+		return err
+	}
+	*t = nt
+	return nil
+}
+
+// orEmpty returns t, or an empty table for the zero value RouteTable, e.g.
+// an unset struct field.
+func (t RouteTable[V]) orEmpty() RouteTable[V] {
+	if t.tree4 == nil || t.tree6 == nil {
+		return New[V]()
+	}
+	return t
+}
+
+func (t RouteTable[V]) addList(list []DumpListNode[V]) error {
+	for _, n := range list {
+		if err := t.TryAdd(n.CIDR, n.Value); err != nil {
+			return err
+		}
+		if err := t.addList(n.Subnets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, one "prefix value" line
+// per route in canonical order. Values are formatted by their MarshalText
+// method if V implements encoding.TextMarshaler, otherwise with fmt.
+func (t RouteTable[V]) MarshalText() ([]byte, error) {
+	t = t.orEmpty()
+	var buf bytes.Buffer
+	var err error
+	t.Walk(func(p netip.Prefix, value V) bool {
+		buf.WriteString(p.String())
+		buf.WriteByte(' ')
+		if m, ok := any(value).(encoding.TextMarshaler); ok {
+			var b []byte
+			if b, err = m.MarshalText(); err != nil {
+				err = fmt.Errorf("ipcritbit: encoding value of %s: %w", p, err)
+				return false
+			}
+			buf.Write(b)
+		} else {
+			fmt.Fprint(&buf, value)
+		}
+		buf.WriteByte('\n')
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
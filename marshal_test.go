@@ -0,0 +1,122 @@
+package ipcritbit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	rtbl := ipcritbit.New[int]()
+	rtbl.Add(netip.MustParsePrefix("192.168.1.0/24"), 2)
+	rtbl.Add(netip.MustParsePrefix("192.168.0.0/16"), 1)
+	rtbl.Add(netip.MustParsePrefix("2001:db8::/32"), 3)
+
+	want := `[{"cidr":"192.168.0.0/16","value":1},{"cidr":"192.168.1.0/24","value":2},{"cidr":"2001:db8::/32","value":3}]`
+	got, err := json.Marshal(rtbl)
+	if err != nil {
+		t.Fatalf("MarshalJSON() - unexpected error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("MarshalJSON() - expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	wantNested := `[{"cidr":"192.168.0.0/16","value":1,"subnets":[{"cidr":"192.168.1.0/24","value":2}]},{"cidr":"2001:db8::/32","value":3}]`
+	got, err = json.Marshal(rtbl.DumpList())
+	if err != nil {
+		t.Fatalf("DumpList() - unexpected error: %v", err)
+	}
+	if string(got) != wantNested {
+		t.Errorf("DumpList() - expected:\n%s\ngot:\n%s", wantNested, got)
+	}
+
+	empty, _ := json.Marshal(ipcritbit.New[int]())
+	if string(empty) != "[]" {
+		t.Errorf("MarshalJSON() - empty table: %s", empty)
+	}
+}
+
+func TestMarshalZero(t *testing.T) {
+	var zero ipcritbit.RouteTable[int]
+	if got, err := json.Marshal(zero); err != nil || string(got) != "[]" {
+		t.Errorf("MarshalJSON() - zero table: %s, %v", got, err)
+	}
+	if got, err := zero.MarshalText(); err != nil || len(got) != 0 {
+		t.Errorf("MarshalText() - zero table: %q, %v", got, err)
+	}
+	if got := zero.DumpList(); len(got) != 0 {
+		t.Errorf("DumpList() - zero table: %v", got)
+	}
+
+	// round trip of a struct with an unset table field
+	type config struct {
+		Routes ipcritbit.RouteTable[int] `json:"routes"`
+	}
+	data, err := json.Marshal(config{})
+	if err != nil {
+		t.Fatalf("MarshalJSON() - unexpected error: %v", err)
+	}
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil || c.Routes.Size() != 0 {
+		t.Errorf("UnmarshalJSON() - zero table: %d routes, %v", c.Routes.Size(), err)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	flat, _ := json.Marshal(rtbl)
+	nested, _ := json.Marshal(rtbl.DumpList())
+
+	for name, data := range map[string][]byte{"flat": flat, "nested": nested} {
+		var got ipcritbit.RouteTable[string]
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("UnmarshalJSON() - %s: %v", name, err)
+		}
+		if got.String() != rtbl.String() {
+			t.Errorf("UnmarshalJSON() - %s: expected:\n%s\ngot:\n%s", name, rtbl, got)
+		}
+	}
+
+	got := ipcritbit.New[string]()
+	if err := json.Unmarshal([]byte(`[{"cidr":"10.0.0.0/33"}]`), &got); err == nil {
+		t.Error("UnmarshalJSON() - invalid prefix accepted")
+	}
+	if err := json.Unmarshal([]byte(`{}`), &got); err == nil {
+		t.Error("UnmarshalJSON() - object accepted")
+	}
+
+	strict := ipcritbit.NewStrict[string]()
+	err := json.Unmarshal([]byte(`[{"cidr":"10.1.2.3/8","value":"x"}]`), &strict)
+	if !errors.Is(err, ipcritbit.ErrNonCanonicalPrefix) {
+		t.Errorf("UnmarshalJSON() - strict: expected ErrNonCanonicalPrefix, got %v", err)
+	}
+	if strict.Size() != 0 {
+		t.Errorf("UnmarshalJSON() - strict: table modified on error")
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	rtbl := ipcritbit.New[netip.Addr]()
+	rtbl.Add(netip.MustParsePrefix("10.0.0.0/8"), netip.MustParseAddr("192.0.2.1"))
+	rtbl.Add(netip.MustParsePrefix("::/0"), netip.MustParseAddr("fe80::1"))
+	rtbl.Add(netip.MustParsePrefix("0.0.0.0/0"), netip.Addr{})
+
+	want := "0.0.0.0/0 \n10.0.0.0/8 192.0.2.1\n::/0 fe80::1\n"
+	got, err := rtbl.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText() - unexpected error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("MarshalText() - expected:\n%q\ngot:\n%q", want, got)
+	}
+
+	ints := ipcritbit.New[int]()
+	ints.Add(netip.MustParsePrefix("10.0.0.0/8"), 42)
+	if got, _ := ints.MarshalText(); string(got) != "10.0.0.0/8 42\n" {
+		t.Errorf("MarshalText() - int values: %q", got)
+	}
+}
@@ -0,0 +1,238 @@
+package ipcritbit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// MRT record types and subtypes, RFC 6396 and RFC 8050.
+const (
+	mrtHeaderLength = 12
+	mrtTableDumpV2  = 13
+
+	mrtRIBIPv4Unicast        = 2
+	mrtRIBIPv6Unicast        = 4
+	mrtRIBIPv4UnicastAddPath = 8
+	mrtRIBIPv6UnicastAddPath = 10
+)
+
+// BGP path attributes, RFC 4271 and RFC 4760.
+const (
+	bgpAttrFlagExtLen = 0x10
+
+	bgpAttrASPath  = 2
+	bgpAttrNextHop = 3
+	bgpAttrMPReach = 14
+	bgpASSequence  = 2
+	bgpASNLen      = 4 // TABLE_DUMP_V2 always encodes 4-octet AS numbers
+)
+
+// MRTRoute is the value of a route imported from an MRT RIB dump, the
+// attributes of the first RIB entry of the prefix, see LoadMRT.
+type MRTRoute struct {
+	NextHop  netip.Addr
+	OriginAS uint32 // last AS of the last AS_SEQUENCE, 0 if none
+	ASPath   []uint32
+}
+
+// LoadMRT adds the routes of an MRT TABLE_DUMP_V2 RIB dump (RFC 6396) to t,
+// as published by RouteViews and RIPE RIS. Only the RIB_IPV4_UNICAST and
+// RIB_IPV6_UNICAST subtypes and their ADD-PATH variants are imported, all
+// other records are skipped.
+//
+// Dumps are usually compressed, wrap r with compress/bzip2 or compress/gzip.
+func LoadMRT(t RouteTable[MRTRoute], r io.Reader) error {
+	br := bufio.NewReader(r)
+	var hdr [mrtHeaderLength]byte
+	for n := 1; ; n++ {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("ipcritbit: mrt record %d: %w", n, err)
+		}
+		typ := binary.BigEndian.Uint16(hdr[4:])
+		subtype := binary.BigEndian.Uint16(hdr[6:])
+		length := int64(binary.BigEndian.Uint32(hdr[8:]))
+
+		// no preallocation, length is not trusted
+		body, err := io.ReadAll(io.LimitReader(br, length))
+		if err == nil && int64(len(body)) != length {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf("ipcritbit: mrt record %d: %w", n, err)
+		}
+		if typ != mrtTableDumpV2 {
+			continue
+		}
+
+		var is4, addPath bool
+		switch subtype {
+		case mrtRIBIPv4Unicast:
+			is4 = true
+		case mrtRIBIPv6Unicast:
+		case mrtRIBIPv4UnicastAddPath:
+			is4, addPath = true, true
+		case mrtRIBIPv6UnicastAddPath:
+			addPath = true
+		default:
+			continue
+		}
+
+		p, value, err := parseRIB(body, is4, addPath)
+		if err != nil {
+			return fmt.Errorf("ipcritbit: mrt record %d: %w", n, err)
+		}
+		if err := t.TryAdd(p, value); err != nil {
+			return fmt.Errorf("ipcritbit: mrt record %d: %w", n, err)
+		}
+	}
+}
+
+var errMRTShort = errors.New("truncated RIB entry")
+
+// parseRIB parses a RIB_IPV4_UNICAST or RIB_IPV6_UNICAST record:
+//
+//	sequence(4) plen(1) prefix(plen) count(2) entries...
+//	entry: peer(2) originated(4) [path id(4)] attrlen(2) attributes
+func parseRIB(b []byte, is4, addPath bool) (netip.Prefix, MRTRoute, error) {
+	var value MRTRoute
+	if len(b) < 5 {
+		return netip.Prefix{}, value, errMRTShort
+	}
+	bits := int(b[4])
+	b = b[5:]
+
+	var a16 [16]byte
+	n := (bits + 7) / 8
+	if n > 16 || len(b) < n+2 {
+		return netip.Prefix{}, value, errMRTShort
+	}
+	copy(a16[:], b[:n])
+	addr := netip.AddrFrom16(a16)
+	if is4 {
+		addr = netip.AddrFrom4([4]byte(a16[:4]))
+	}
+	p := netip.PrefixFrom(addr, bits)
+	if !p.IsValid() {
+		return p, value, fmt.Errorf("prefix length %d", bits)
+	}
+
+	count := binary.BigEndian.Uint16(b[n:])
+	b = b[n+2:]
+	if count == 0 {
+		return p, value, nil
+	}
+
+	// attributes of the first entry only
+	skip := 6
+	if addPath {
+		skip += 4
+	}
+	if len(b) < skip+2 {
+		return p, value, errMRTShort
+	}
+	alen := int(binary.BigEndian.Uint16(b[skip:]))
+	b = b[skip+2:]
+	if len(b) < alen {
+		return p, value, errMRTShort
+	}
+	err := parseBGPAttrs(b[:alen], &value)
+	return p, value, err
+}
+
+// parseBGPAttrs extracts AS path and next hop.
+func parseBGPAttrs(b []byte, value *MRTRoute) error {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return errMRTShort
+		}
+		flags, typ := b[0], b[1]
+		var alen int
+		if flags&bgpAttrFlagExtLen != 0 {
+			if len(b) < 4 {
+				return errMRTShort
+			}
+			alen = int(binary.BigEndian.Uint16(b[2:]))
+			b = b[4:]
+		} else {
+			alen = int(b[2])
+			b = b[3:]
+		}
+		if len(b) < alen {
+			return errMRTShort
+		}
+		attr := b[:alen]
+		b = b[alen:]
+
+		switch typ {
+		case bgpAttrASPath:
+			if err := parseASPath(attr, value); err != nil {
+				return err
+			}
+		case bgpAttrNextHop:
+			if len(attr) != 4 {
+				return fmt.Errorf("bad NEXT_HOP length %d", len(attr))
+			}
+			value.NextHop = netip.AddrFrom4([4]byte(attr))
+		case bgpAttrMPReach:
+			nh, err := parseMPReachNextHop(attr)
+			if err != nil {
+				return err
+			}
+			value.NextHop = nh
+		}
+	}
+	return nil
+}
+
+// parseASPath flattens all segments, AS_SETs included.
+func parseASPath(b []byte, value *MRTRoute) error {
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return errMRTShort
+		}
+		typ, count := b[0], int(b[1])
+		b = b[2:]
+		if len(b) < count*bgpASNLen {
+			return errMRTShort
+		}
+		for i := 0; i < count; i++ {
+			value.ASPath = append(value.ASPath, binary.BigEndian.Uint32(b[i*bgpASNLen:]))
+		}
+		b = b[count*bgpASNLen:]
+		if typ == bgpASSequence && count > 0 {
+			value.OriginAS = value.ASPath[len(value.ASPath)-1]
+		}
+	}
+	return nil
+}
+
+// parseMPReachNextHop parses the next hop of MP_REACH_NLRI. RFC 6396 encodes
+// only length and next hop, some writers emit the full attribute with AFI
+// and SAFI. Of a global and link-local IPv6 pair the global one is used.
+func parseMPReachNextHop(b []byte) (netip.Addr, error) {
+	if len(b) > 0 && int(b[0]) != len(b)-1 {
+		// full form: afi(2) safi(1) nhlen(1) next hop ...
+		if len(b) < 4 {
+			return netip.Addr{}, errMRTShort
+		}
+		b = b[3:]
+	}
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return netip.Addr{}, errMRTShort
+	}
+	nh := b[1 : 1+int(b[0])]
+	switch len(nh) {
+	case 4:
+		return netip.AddrFrom4([4]byte(nh)), nil
+	case 16, 32:
+		return netip.AddrFrom16([16]byte(nh[:16])), nil
+	}
+	return netip.Addr{}, fmt.Errorf("bad MP_REACH_NLRI next hop length %d", len(nh))
+}
@@ -0,0 +1,160 @@
+package ipcritbit_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+// mrtRecord encodes an MRT record with the common header.
+func mrtRecord(typ, subtype uint16, body []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 1700000000)
+	b = binary.BigEndian.AppendUint16(b, typ)
+	b = binary.BigEndian.AppendUint16(b, subtype)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)))
+	return append(b, body...)
+}
+
+// bgpAttr encodes a path attribute, extended length if needed.
+func bgpAttr(typ byte, value []byte) []byte {
+	if len(value) > 255 {
+		b := []byte{0x50, typ}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+		return append(b, value...)
+	}
+	return append([]byte{0x40, typ, byte(len(value))}, value...)
+}
+
+func asPath(asns ...uint32) []byte {
+	b := []byte{2, byte(len(asns))}
+	for _, asn := range asns {
+		b = binary.BigEndian.AppendUint32(b, asn)
+	}
+	return b
+}
+
+// ribRecord encodes a RIB_IPV4_UNICAST or RIB_IPV6_UNICAST record with
+// one entry per attribute set.
+func ribRecord(subtype uint16, pfx netip.Prefix, addPath bool, attrs ...[]byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 7)
+	b = append(b, byte(pfx.Bits()))
+	b = append(b, pfx.Addr().AsSlice()[:(pfx.Bits()+7)/8]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(attrs)))
+	for i, a := range attrs {
+		b = binary.BigEndian.AppendUint16(b, uint16(i))
+		b = binary.BigEndian.AppendUint32(b, 1700000000)
+		if addPath {
+			b = binary.BigEndian.AppendUint32(b, 42)
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a)))
+		b = append(b, a...)
+	}
+	return mrtRecord(13, subtype, b)
+}
+
+func buildMRT() []byte {
+	var dump []byte
+
+	// PEER_INDEX_TABLE and BGP4MP records are skipped
+	dump = append(dump, mrtRecord(13, 1, []byte{1, 2, 3, 4, 0, 0, 0, 0})...)
+	dump = append(dump, mrtRecord(16, 4, []byte{0xde, 0xad})...)
+
+	attrs := append(bgpAttr(1, []byte{0}), bgpAttr(2, asPath(3356, 1299, 64500))...)
+	attrs = append(attrs, bgpAttr(3, []byte{192, 0, 2, 1})...)
+	other := append(bgpAttr(2, asPath(174, 64501)), bgpAttr(3, []byte{192, 0, 2, 2})...)
+	dump = append(dump, ribRecord(2, netip.MustParsePrefix("198.51.100.0/24"), false, attrs, other)...)
+
+	// long AS path with extended length, AS_SET at the end
+	long := make([]uint32, 70)
+	for i := range long {
+		long[i] = uint32(65000 + i)
+	}
+	set := append(asPath(long...), 1, 2, 0, 0, 0, 1, 0, 0, 0, 2)
+	attrs = append(bgpAttr(2, set), bgpAttr(3, []byte{192, 0, 2, 3})...)
+	dump = append(dump, ribRecord(8, netip.MustParsePrefix("203.0.113.0/25"), true, attrs)...)
+
+	// IPv6, abbreviated MP_REACH_NLRI with global and link-local next hop
+	nh := append([]byte{32}, netip.MustParseAddr("2001:db8::1").AsSlice()...)
+	nh = append(nh, netip.MustParseAddr("fe80::1").AsSlice()...)
+	attrs = append(bgpAttr(2, asPath(6939, 64502)), bgpAttr(14, nh)...)
+	dump = append(dump, ribRecord(4, netip.MustParsePrefix("2001:db8:100::/40"), false, attrs)...)
+
+	// IPv6, full MP_REACH_NLRI form, add-path
+	full := []byte{0, 2, 1, 16}
+	full = append(full, netip.MustParseAddr("2001:db8::2").AsSlice()...)
+	full = append(full, 0, 0) // reserved, empty NLRI
+	attrs = append(bgpAttr(2, asPath(2914)), bgpAttr(14, full)...)
+	dump = append(dump, ribRecord(10, netip.MustParsePrefix("::/0"), true, attrs)...)
+
+	return dump
+}
+
+func TestLoadMRT(t *testing.T) {
+	rtbl := ipcritbit.New[ipcritbit.MRTRoute]()
+	if err := ipcritbit.LoadMRT(rtbl, bytes.NewReader(buildMRT())); err != nil {
+		t.Fatalf("LoadMRT() - unexpected error: %v", err)
+	}
+	if rtbl.Size() != 4 {
+		t.Errorf("LoadMRT() - expected 4 routes, got %d", rtbl.Size())
+	}
+
+	tests := []struct {
+		probe, route, nextHop string
+		origin                uint32
+		pathLen               int
+	}{
+		{"198.51.100.7", "198.51.100.0/24", "192.0.2.1", 64500, 3},
+		{"203.0.113.1", "203.0.113.0/25", "192.0.2.3", 65069, 72},
+		{"2001:db8:100::1", "2001:db8:100::/40", "2001:db8::1", 64502, 2},
+		{"2001:db8:200::1", "::/0", "2001:db8::2", 2914, 1},
+	}
+	for _, tt := range tests {
+		r, v, ok := rtbl.Lookup(netip.MustParseAddr(tt.probe))
+		if !ok || r.String() != tt.route {
+			t.Errorf("Lookup(%s) - expected [%s], actual [%s]", tt.probe, tt.route, r)
+			continue
+		}
+		if v.NextHop.String() != tt.nextHop || v.OriginAS != tt.origin || len(v.ASPath) != tt.pathLen {
+			t.Errorf("Lookup(%s) - unexpected value %+v", tt.probe, v)
+		}
+	}
+
+	v, _ := rtbl.Get(netip.MustParsePrefix("198.51.100.0/24"))
+	if !slices.Equal(v.ASPath, []uint32{3356, 1299, 64500}) {
+		t.Errorf("LoadMRT() - AS path: %v", v.ASPath)
+	}
+}
+
+func TestLoadMRTErrors(t *testing.T) {
+	dump := buildMRT()
+
+	// truncation at a record boundary is a clean end, everywhere else an error
+	boundaries := map[int]bool{0: true}
+	for off := 0; off < len(dump); {
+		off += 12 + int(binary.BigEndian.Uint32(dump[off+8:]))
+		boundaries[off] = true
+	}
+	for i := 0; i < len(dump); i++ {
+		err := ipcritbit.LoadMRT(ipcritbit.New[ipcritbit.MRTRoute](), bytes.NewReader(dump[:i]))
+		if boundaries[i] != (err == nil) {
+			t.Fatalf("LoadMRT() - truncated at %d: %v", i, err)
+		}
+	}
+
+	// garbage inside a well-formed record
+	bad := mrtRecord(13, 2, []byte{0, 0, 0, 1, 40, 10, 0, 0, 0, 0, 0, 0})
+	err := ipcritbit.LoadMRT(ipcritbit.New[ipcritbit.MRTRoute](), bytes.NewReader(bad))
+	if err == nil || !strings.Contains(err.Error(), "mrt record 1") {
+		t.Errorf("LoadMRT() - bad prefix length: %v", err)
+	}
+
+	badNH := ribRecord(2, netip.MustParsePrefix("10.0.0.0/8"), false, bgpAttr(3, []byte{1, 2, 3}))
+	if err := ipcritbit.LoadMRT(ipcritbit.New[ipcritbit.MRTRoute](), bytes.NewReader(badNH)); err == nil {
+		t.Error("LoadMRT() - bad NEXT_HOP accepted")
+	}
+}
@@ -1,107 +1,321 @@
 package ipcritbit
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"io"
 	"net/netip"
 )
 
-// IP routing table.
-type RouteTable struct {
-	tree4 *critBitTree
-	tree6 *critBitTree
+var (
+	// ErrInvalidPrefix is returned for the zero or otherwise invalid netip.Prefix.
+	ErrInvalidPrefix = errors.New("ipcritbit: invalid prefix")
+
+	// ErrNonCanonicalPrefix is returned by a strict table for prefixes with
+	// host bits set, e.g. 10.1.2.3/8.
+	ErrNonCanonicalPrefix = errors.New("ipcritbit: non-canonical prefix")
+)
+
+// IP routing table, generic over the value type V.
+//
+// Callers migrating from the untyped API can use RouteTable[any],
+// created with New[any]().
+//
+// Prefixes are stored in their canonical, masked form: 10.1.2.3/8 and
+// 10.0.0.0/8 denote the same route.
+type RouteTable[V any] struct {
+	tree4  *critBitTree[V]
+	tree6  *critBitTree[V]
+	strict bool
 }
 
 // Create IP routing table
-func New() RouteTable {
-	return RouteTable{
-		tree4: newTree(),
-		tree6: newTree(),
+func New[V any]() RouteTable[V] {
+	return RouteTable[V]{
+		tree4: newTree[V](),
+		tree6: newTree[V](),
 	}
 }
 
+// Create IP routing table in strict mode.
+//
+// A strict table rejects prefixes with host bits set instead of masking
+// them, see ErrNonCanonicalPrefix.
+func NewStrict[V any]() RouteTable[V] {
+	t := New[V]()
+	t.strict = true
+	return t
+}
+
 // Add a route.
-func (t RouteTable) Add(p netip.Prefix, value interface{}) {
-	key := pfxToKey(p)
+// Add panics if p is invalid or, in strict mode, not canonical. Use TryAdd
+// for untrusted input.
+func (t RouteTable[V]) Add(p netip.Prefix, value V) {
+	if err := t.TryAdd(p, value); err != nil {
+		panic(err)
+	}
+}
+
+// TryAdd adds a route, returning ErrInvalidPrefix or ErrNonCanonicalPrefix
+// instead of panicking.
+func (t RouteTable[V]) TryAdd(p netip.Prefix, value V) error {
+	p, err := t.canonical(p)
+	if err != nil {
+		return err
+	}
+	var buf [maxKeyLen]byte
+	key := pfxToKey(&buf, p)
 	if p.Addr().Is4() {
 		t.tree4.set(key, value)
-		return
+		return nil
 	}
 	t.tree6.set(key, value)
+	return nil
 }
 
 // Delete a specific route.
-func (t RouteTable) Delete(p netip.Prefix) (value interface{}, ok bool) {
+// An invalid or rejected prefix is reported as a miss.
+func (t RouteTable[V]) Delete(p netip.Prefix) (value V, ok bool) {
+	value, ok, _ = t.TryDelete(p)
+	return
+}
+
+// TryDelete deletes a specific route, returning an error if p is invalid
+// or, in strict mode, not canonical.
+func (t RouteTable[V]) TryDelete(p netip.Prefix) (value V, ok bool, err error) {
+	if p, err = t.canonical(p); err != nil {
+		return
+	}
+	var buf [maxKeyLen]byte
+	key := pfxToKey(&buf, p)
 	if p.Addr().Is4() {
-		return t.tree4.delete(pfxToKey(p))
+		value, ok = t.tree4.delete(key)
+		return
 	}
-	return t.tree6.delete(pfxToKey(p))
+	value, ok = t.tree6.delete(key)
+	return
 }
 
 // Get a specific route.
-func (t RouteTable) Get(p netip.Prefix) (value interface{}, ok bool) {
+// An invalid or rejected prefix is reported as a miss.
+func (t RouteTable[V]) Get(p netip.Prefix) (value V, ok bool) {
+	value, ok, _ = t.TryGet(p)
+	return
+}
+
+// TryGet returns a specific route, returning an error if p is invalid
+// or, in strict mode, not canonical.
+func (t RouteTable[V]) TryGet(p netip.Prefix) (value V, ok bool, err error) {
+	if p, err = t.canonical(p); err != nil {
+		return
+	}
+	var buf [maxKeyLen]byte
+	key := pfxToKey(&buf, p)
 	if p.Addr().Is4() {
-		return t.tree4.get(pfxToKey(p))
+		value, ok = t.tree4.get(key)
+		return
 	}
-	return t.tree6.get(pfxToKey(p))
+	value, ok = t.tree6.get(key)
+	return
 }
 
 // Return a specific route by using the longest prefix matching.
-func (t RouteTable) LookupCIDR(p netip.Prefix) (route netip.Prefix, value interface{}) {
-	if p.Addr().Is4() {
-		if k, v := t.match4(pfxToKey(p)); k != nil {
-			unmarshal(&route, k)
-			value = v
-		}
+// An invalid or rejected prefix is reported as a miss.
+func (t RouteTable[V]) LookupCIDR(p netip.Prefix) (route netip.Prefix, value V) {
+	route, value, _ = t.LookupPrefix(p)
+	return
+}
+
+// LookupPrefix is like LookupCIDR, ok reports whether a route was found.
+func (t RouteTable[V]) LookupPrefix(p netip.Prefix) (route netip.Prefix, value V, ok bool) {
+	p, err := t.canonical(p)
+	if err != nil {
 		return
 	}
-	if k, v := t.match6(pfxToKey(p)); k != nil {
-		unmarshal(&route, k)
-		value = v
+	var buf [maxKeyLen]byte
+	var k []byte
+	if p.Addr().Is4() {
+		k, value = t.match4(pfxToKey(&buf, p))
+	} else {
+		k, value = t.match6(pfxToKey(&buf, p))
+	}
+	if k != nil {
+		route = keyToPfx(k)
+		ok = true
 	}
 	return
 }
 
 // Return a specific route by using the longest prefix matching.
-func (t RouteTable) LookupIP(ip netip.Addr) (route netip.Prefix, value interface{}) {
-	k, v := t.matchIP(ip)
+// An invalid address is reported as a miss, a zone is ignored.
+func (t RouteTable[V]) LookupIP(ip netip.Addr) (route netip.Prefix, value V) {
+	route, value, _ = t.Lookup(ip)
+	return
+}
+
+// Lookup is like LookupIP, ok reports whether a route was found.
+func (t RouteTable[V]) Lookup(ip netip.Addr) (route netip.Prefix, value V, ok bool) {
+	if !ip.IsValid() {
+		return
+	}
+	var buf [maxKeyLen]byte
+	var k []byte
+	if ip.Is4() {
+		k, value = t.match4(ipToKey(&buf, ip))
+	} else {
+		k, value = t.match6(ipToKey(&buf, ip))
+	}
 	if k != nil {
-		unmarshal(&route, k)
-		value = v
+		route = keyToPfx(k)
+		ok = true
 	}
 	return
 }
 
-func (t RouteTable) matchIP(ip netip.Addr) (k []byte, v interface{}) {
+// Contains reports whether any route covers ip.
+// An invalid address is reported as a miss, a zone is ignored.
+func (t RouteTable[V]) Contains(ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	var buf [maxKeyLen]byte
 	if ip.Is4() {
-		p := netip.PrefixFrom(ip, 32)
-		k, v = t.match4(pfxToKey(p))
-		return
+		return t.tree4.covers(ipToKey(&buf, ip))
 	}
-	p := netip.PrefixFrom(ip, 128)
-	k, v = t.match6(pfxToKey(p))
-	return
+	return t.tree6.covers(ipToKey(&buf, ip))
+}
+
+// ContainsPrefix reports whether any route covers p.
+// An invalid or rejected prefix is reported as a miss.
+func (t RouteTable[V]) ContainsPrefix(p netip.Prefix) bool {
+	p, err := t.canonical(p)
+	if err != nil {
+		return false
+	}
+	var buf [maxKeyLen]byte
+	if p.Addr().Is4() {
+		return t.tree4.covers(pfxToKey(&buf, p))
+	}
+	return t.tree6.covers(pfxToKey(&buf, p))
 }
 
-func (t RouteTable) match4(key []byte) ([]byte, interface{}) {
+// OverlapsPrefix reports whether any route overlaps with p, either as
+// supernet or as subnet.
+// An invalid or rejected prefix is reported as a miss.
+func (t RouteTable[V]) OverlapsPrefix(p netip.Prefix) bool {
+	p, err := t.canonical(p)
+	if err != nil {
+		return false
+	}
+	var buf [maxKeyLen]byte
+	if p.Addr().Is4() {
+		return t.tree4.overlaps(pfxToKey(&buf, p))
+	}
+	return t.tree6.overlaps(pfxToKey(&buf, p))
+}
+
+// Overlaps reports whether any route in t overlaps with any route in o.
+//
+// Both critbit trees are descended together, pairs of subtrees spanning
+// disjoint address blocks are pruned. The descent stops at the first overlap.
+func (t RouteTable[V]) Overlaps(o RouteTable[V]) bool {
+	return overlapsTree(t.tree4, o.tree4) || overlapsTree(t.tree6, o.tree6)
+}
+
+func overlapsTree[V any](a, b *critBitTree[V]) bool {
+	if a.items == 0 || b.items == 0 {
+		return false
+	}
+	return overlapsNode(&a.root, &b.root)
+}
+
+// overlapsNode splits the subtree with the larger span until the spans are
+// disjoint or a leaf covers the span of the other subtree.
+func overlapsNode[V any](a, b *node[V]) bool {
+	sa, sb := a.span(), b.span()
+	switch {
+	case !sa.Overlaps(sb):
+		return false
+	case a.external != nil && covers(sa, sb):
+		return true
+	case b.external != nil && covers(sb, sa):
+		return true
+	}
+
+	// two overlapping leaves are caught above, at least one is internal
+	if b.internal == nil || a.internal != nil && sa.Bits() <= sb.Bits() {
+		return overlapsNode(&a.internal.child[0], b) || overlapsNode(&a.internal.child[1], b)
+	}
+	return overlapsNode(a, &b.internal.child[0]) || overlapsNode(a, &b.internal.child[1])
+}
+
+// span returns the smallest prefix containing all routes below n.
+//
+// The keys below an internal node share the address bits before the
+// critical bit. The routes are within this block or, with a shorter mask,
+// supernets of it. Such supernets have the address of the block followed by
+// zero bits, they sort first and the leftmost leaf is the shortest of them.
+func (n *node[V]) span() netip.Prefix {
+	leaf := n
+	for leaf.internal != nil {
+		leaf = &leaf.internal.child[0]
+	}
+	pfx := keyToPfx(leaf.external.key())
+	if n.internal == nil {
+		return pfx
+	}
+	bits := min(n.internal.bitPos(), pfx.Addr().BitLen())
+	if pfx.Bits() < bits {
+		return pfx
+	}
+	block, _ := pfx.Addr().Prefix(bits)
+	return block
+}
+
+// overlaps reports whether key has a supernet or a subnet in the tree.
+func (t *critBitTree[V]) overlaps(key []byte) bool {
+	if t.covers(key) {
+		return true
+	}
+	var found bool
+	t.walkPrefixed(key, int(key[len(key)-1]), func([]byte, V) bool {
+		// any key with the same leading bits is a subnet, supernets are
+		// already ruled out above
+		found = true
+		return false
+	})
+	return found
+}
+
+// covers stops at the first matching leaf, the route is not converted
+// back to a prefix.
+func (t *critBitTree[V]) covers(key []byte) bool {
+	return t.items > 0 && lookup(&t.root, key, false) != nil
+}
+
+func (t RouteTable[V]) match4(key []byte) ([]byte, V) {
+	var zero V
 	if t.tree4.items > 0 {
 		if node := lookup(&t.tree4.root, key, false); node != nil {
-			return node.external.key, node.external.value
+			return node.external.key(), node.external.value
 		}
 	}
-	return nil, nil
+	return nil, zero
 }
 
-func (t RouteTable) match6(key []byte) ([]byte, interface{}) {
+func (t RouteTable[V]) match6(key []byte) ([]byte, V) {
+	var zero V
 	if t.tree6.items > 0 {
 		if node := lookup(&t.tree6.root, key, false); node != nil {
-			return node.external.key, node.external.value
+			return node.external.key(), node.external.value
 		}
 	}
-	return nil, nil
+	return nil, zero
 }
 
 // lookup is IP prefix specific, see pfxToKey.
-func lookup(p *node, key []byte, backtracking bool) *node {
+func lookup[V any](p *node[V], key []byte, backtracking bool) *node[V] {
 	if p.internal != nil {
 		var direction int
 		if p.internal.offset == len(key)-1 {
@@ -122,13 +336,14 @@ func lookup(p *node, key []byte, backtracking bool) *node {
 		}
 		return nil
 	} else {
-		nlen := len(p.external.key)
+		pkey := p.external.key()
+		nlen := len(pkey)
 		if nlen != len(key) {
 			return nil
 		}
 
 		// check mask
-		mask := p.external.key[nlen-1]
+		mask := pkey[nlen-1]
 		if mask > key[nlen-1] {
 			return nil
 		}
@@ -136,13 +351,13 @@ func lookup(p *node, key []byte, backtracking bool) *node {
 		// compare both keys with mask
 		div := int(mask >> 3)
 		for i := 0; i < div; i++ {
-			if p.external.key[i] != key[i] {
+			if pkey[i] != key[i] {
 				return nil
 			}
 		}
 		if mod := uint(mask & 0x07); mod > 0 {
 			bit := 8 - mod
-			if p.external.key[div] != key[div]&(0xff>>bit<<bit) {
+			if pkey[div] != key[div]&(0xff>>bit<<bit) {
 				return nil
 			}
 		}
@@ -150,60 +365,219 @@ func lookup(p *node, key []byte, backtracking bool) *node {
 	}
 }
 
-// Walk iterates all routes.
-// callback is called with route and value as argumets (if callback returns `false`, the iteration is aborted)
-func (t RouteTable) Walk(callback func(prefix netip.Prefix, value interface{}) bool) {
-	t.tree4.walk(func(currentKey []byte, value interface{}) bool {
-		return callback(keyToPfx(currentKey), value)
+// Walk iterates all routes in canonical order, see Compare.
+// callback is called with route and value as arguments (if callback returns `false`, the iteration is aborted)
+//
+// An abort is final, after an abort within the IPv4 routes no IPv6 route is visited.
+func (t RouteTable[V]) Walk(callback func(prefix netip.Prefix, value V) bool) {
+	_ = t.tree4.all(callback) && t.tree6.all(callback)
+}
+
+// Walk4 iterates all IPv4 routes in canonical order, see Walk.
+func (t RouteTable[V]) Walk4(callback func(prefix netip.Prefix, value V) bool) {
+	t.tree4.all(callback)
+}
+
+// Walk6 iterates all IPv6 routes in canonical order, see Walk.
+func (t RouteTable[V]) Walk6(callback func(prefix netip.Prefix, value V) bool) {
+	t.tree6.all(callback)
+}
+
+// all calls callback for all routes of the tree, false if aborted by callback.
+func (t *critBitTree[V]) all(callback func(netip.Prefix, V) bool) bool {
+	return t.walk(func(key []byte, value V) bool {
+		return callback(keyToPfx(key), value)
 	})
+}
+
+// LookupAll iterates all routes covering ip, from the most to the least
+// specific one, e.g. /32, /28, /24, /16, /0.
+// callback is called with route and value as arguments (if callback returns `false`, the iteration is aborted)
+func (t RouteTable[V]) LookupAll(ip netip.Addr, callback func(prefix netip.Prefix, value V) bool) {
+	if !ip.IsValid() {
+		return
+	}
+	var buf [maxKeyLen]byte
+	if ip.Is4() {
+		t.tree4.supernets(ipToKey(&buf, ip), callback)
+		return
+	}
+	t.tree6.supernets(ipToKey(&buf, ip), callback)
+}
+
+// WalkSupernets iterates all routes covering p, p itself included, from
+// the most to the least specific one.
+// callback is called with route and value as arguments (if callback returns `false`, the iteration is aborted)
+func (t RouteTable[V]) WalkSupernets(p netip.Prefix, callback func(prefix netip.Prefix, value V) bool) {
+	p, err := t.canonical(p)
+	if err != nil {
+		return
+	}
+	var buf [maxKeyLen]byte
+	if p.Addr().Is4() {
+		t.tree4.supernets(pfxToKey(&buf, p), callback)
+		return
+	}
+	t.tree6.supernets(pfxToKey(&buf, p), callback)
+}
+
+// WalkSubnets iterates all routes covered by p, p itself included, in
+// canonical order.
+// callback is called with route and value as arguments (if callback returns `false`, the iteration is aborted)
+func (t RouteTable[V]) WalkSubnets(p netip.Prefix, callback func(prefix netip.Prefix, value V) bool) {
+	p, err := t.canonical(p)
+	if err != nil {
+		return
+	}
+	var buf [maxKeyLen]byte
+	if p.Addr().Is4() {
+		t.tree4.subnets(pfxToKey(&buf, p), callback)
+		return
+	}
+	t.tree6.subnets(pfxToKey(&buf, p), callback)
+}
 
-	t.tree6.walk(func(currentKey []byte, value interface{}) bool {
-		return callback(keyToPfx(currentKey), value)
+// subnets descends to the subtree sharing the address bits of key, within
+// that subtree only supernets of key with the same leading bits are skipped.
+func (t *critBitTree[V]) subnets(key []byte, callback func(netip.Prefix, V) bool) {
+	last := len(key) - 1
+	mask := key[last]
+	t.walkPrefixed(key, int(mask), func(k []byte, v V) bool {
+		if k[last] < mask {
+			return true
+		}
+		return callback(keyToPfx(k), v)
 	})
 }
 
+// supernets repeats the longest prefix match, each time with the mask of
+// the key shortened below the last match. The key is modified.
+func (t *critBitTree[V]) supernets(key []byte, callback func(netip.Prefix, V) bool) {
+	if t.items == 0 {
+		return
+	}
+	last := len(key) - 1
+	for {
+		n := lookup(&t.root, key, false)
+		if n == nil {
+			return
+		}
+		nkey := n.external.key()
+		if !callback(keyToPfx(nkey), n.external.value) {
+			return
+		}
+		mask := nkey[last]
+		if mask == 0 {
+			return
+		}
+		key[last] = mask - 1
+	}
+}
+
 // Dump routing table. (for debugging)
-func (t RouteTable) Dump(w io.Writer) {
+func (t RouteTable[V]) Dump(w io.Writer) {
 	t.tree4.dump(w)
 	t.tree6.dump(w)
 }
 
+// Clone returns a copy of the routing table, the critbit trees are
+// duplicated, the values are copied by assignment.
+func (t RouteTable[V]) Clone() RouteTable[V] {
+	return t.CloneFunc(func(v V) V { return v })
+}
+
+// CloneFunc is like Clone, the values are copied by cp, e.g. a deep copy
+// for pointer values.
+func (t RouteTable[V]) CloneFunc(cp func(V) V) RouteTable[V] {
+	return RouteTable[V]{
+		tree4:  t.tree4.clone(cp),
+		tree6:  t.tree6.clone(cp),
+		strict: t.strict,
+	}
+}
+
 // Deletes all routes.
-func (t RouteTable) Clear() {
+func (t RouteTable[V]) Clear() {
 	t.tree4.clear()
 	t.tree6.clear()
 }
 
 // Returns number of routes.
-func (t RouteTable) Size() int {
+func (t RouteTable[V]) Size() int {
 	return t.tree4.items + t.tree6.items
 }
 
+// Compare returns an integer comparing two prefixes in canonical order,
+// the order of Walk, Walk4, Walk6, WalkSubnets and the iterators All, All4,
+// All6 and Subnets:
+// IPv4 before IPv6, address ascending, supernet before subnet.
+// LookupAll, WalkSupernets and their iterators yield the most specific
+// route first.
+// The result is 0 if a == b, -1 if a < b, and +1 if a > b.
+//
+// For masked prefixes this is the byte order of the keys in the critbit
+// trees, address followed by mask. Invalid prefixes sort first.
+func Compare(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Bits(), b.Bits())
+}
+
+// canonical returns the masked form of p, or an error if p is invalid or,
+// in strict mode, has host bits set.
+func (t RouteTable[V]) canonical(p netip.Prefix) (netip.Prefix, error) {
+	if !p.IsValid() {
+		return p, ErrInvalidPrefix
+	}
+	m := p.Masked()
+	if t.strict && m != p {
+		return p, fmt.Errorf("%w: %s", ErrNonCanonicalPrefix, p)
+	}
+	return m, nil
+}
+
 // helpers, convert between keys ([]byte) and netip.Prefix
+//
+// The key is written to a caller provided fixed size buffer, the hot paths
+// do not allocate.
 
 // +---------------------+
 // + <------ bytes ----->|
 // +--------------+------+
 // | ip address.. | mask |
 // +--------------+------+
-func pfxToKey(p netip.Prefix) []byte {
+//
+// IPv4: 4+1 bytes, IPv6: 16+1 bytes, the layout of netip.Prefix.MarshalBinary.
+func pfxToKey(buf *[maxKeyLen]byte, p netip.Prefix) []byte {
 	if !p.IsValid() {
 		panic("invalid Prefix")
 	}
-	b, _ := p.MarshalBinary()
-	return b
+	return addrToKey(buf, p.Addr(), p.Bits())
 }
 
-func keyToPfx(key []byte) netip.Prefix {
-	p := netip.Prefix{}
-	unmarshal(&p, key)
-	return p
+// ipToKey returns the key of the host route for ip, a zone is ignored.
+func ipToKey(buf *[maxKeyLen]byte, ip netip.Addr) []byte {
+	return addrToKey(buf, ip, ip.BitLen())
 }
 
-// ummarshal does not allocate.
-func unmarshal(p *netip.Prefix, b []byte) {
-	err := p.UnmarshalBinary(b)
-	if err != nil {
-		panic(err)
+func addrToKey(buf *[maxKeyLen]byte, ip netip.Addr, bits int) []byte {
+	if ip.Is4() {
+		a := ip.As4()
+		copy(buf[:], a[:])
+		buf[4] = byte(bits)
+		return buf[:5]
+	}
+	a := ip.As16()
+	copy(buf[:], a[:])
+	buf[16] = byte(bits)
+	return buf[:17]
+}
+
+// keyToPfx does not allocate.
+func keyToPfx(key []byte) netip.Prefix {
+	if len(key) == 5 {
+		return netip.PrefixFrom(netip.AddrFrom4([4]byte(key[:4])), int(key[4]))
 	}
+	return netip.PrefixFrom(netip.AddrFrom16([16]byte(key[:16])), int(key[16]))
 }
@@ -31,8 +31,8 @@ func genCIDR(rand *rand.Rand) netip.Prefix {
 	return netip.PrefixFrom(addr, bits)
 }
 
-func buildRTable(keys []netip.Prefix) ipcritbit.RouteTable {
-	rtbl := ipcritbit.New()
+func buildRTable(keys []netip.Prefix) ipcritbit.RouteTable[any] {
+	rtbl := ipcritbit.New[any]()
 	for i := 0; i < len(keys); i++ {
 		rtbl.Add(keys[i], nil)
 	}
@@ -45,10 +45,10 @@ func BenchmarkNetipBuild(b *testing.B) {
 	}
 }
 
-/*
 func BenchmarkNetipGet(b *testing.B) {
 	rtbl := buildRTable(cidrs)
 	random := rand.New(rand.NewSource(0))
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -60,22 +60,35 @@ func BenchmarkNetipGet(b *testing.B) {
 func BenchmarkNetipDelete(b *testing.B) {
 	rtbl := buildRTable(cidrs)
 	random := rand.New(rand.NewSource(0))
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		k := cidrs[random.Intn(keyCount)]
+		k := cidrs[random.Intn(routeCount2)]
 		rtbl.Delete(k)
 	}
 }
 
-func BenchmarkNetipMatch(b *testing.B) {
+func BenchmarkNetipLookupCIDR(b *testing.B) {
+	rtbl := buildRTable(cidrs)
+	random := rand.New(rand.NewSource(0))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s := genCIDR(random)
+		rtbl.LookupCIDR(s)
+	}
+}
+
+func BenchmarkNetipLookupIP(b *testing.B) {
 	rtbl := buildRTable(cidrs)
 	random := rand.New(rand.NewSource(0))
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		s := genCIDR(random)
-		rtbl.Match(s)
+		rtbl.LookupIP(s.Addr())
 	}
 }
-*/
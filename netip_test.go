@@ -1,14 +1,17 @@
 package ipcritbit_test
 
 import (
+	"errors"
+	"math/rand"
 	"net/netip"
+	"slices"
 	"testing"
 
 	"github.com/gaissmai/ipcritbit"
 )
 
 func TestNetip(t *testing.T) {
-	rtbl := ipcritbit.New()
+	rtbl := ipcritbit.New[any]()
 
 	addr4 := netip.MustParseAddr("192.168.1.1")
 	host4 := netip.MustParsePrefix("192.168.1.1/32")
@@ -43,27 +46,19 @@ func TestNetip(t *testing.T) {
 	}
 }
 
-func checkMatchIP(t *testing.T, rtbl ipcritbit.RouteTable, probe, expect string) {
+func checkMatchIP(t *testing.T, rtbl ipcritbit.RouteTable[string], probe, expect string) {
 	ip := netip.MustParseAddr(probe)
 	route, value := rtbl.LookupIP(ip)
 	if cidr := route.String(); expect != cidr {
 		t.Errorf("MatchIP() - %s: expected [%s], actual [%s]", probe, expect, cidr)
 	}
-	if value == nil {
-		t.Errorf("MatchIP() - %s: no value", probe)
-	}
-
-	s, ok := value.(string)
-	if !ok {
-		t.Errorf("MatchIP() - %s: value is not of type string", probe)
-	}
-	if s != route.String() {
-		t.Errorf("MatchIP() - %s: expected [%s], got [%s]", probe, route.String(), s)
+	if value != route.String() {
+		t.Errorf("MatchIP() - %s: expected [%s], got [%s]", probe, route.String(), value)
 	}
 }
 
-func buildTestNetip(t *testing.T) ipcritbit.RouteTable {
-	rtbl := ipcritbit.New()
+func buildTestNetip(t *testing.T) ipcritbit.RouteTable[string] {
+	rtbl := ipcritbit.New[string]()
 
 	cidrs := []netip.Prefix{
 		netip.MustParsePrefix("10.0.0.0/8"),
@@ -118,7 +113,7 @@ func TestNetipWalk(t *testing.T) {
 	rtbl := buildTestNetip(t)
 
 	var c int
-	f := func(p netip.Prefix, v interface{}) bool {
+	f := func(p netip.Prefix, v string) bool {
 		c += 1
 		return true
 	}
@@ -128,4 +123,744 @@ func TestNetipWalk(t *testing.T) {
 	if c != 15 {
 		t.Errorf("Walk() - %d: full walk", c)
 	}
+
+	c = 0
+	rtbl.Walk4(f)
+	if c != 11 {
+		t.Errorf("Walk4() - %d: full walk", c)
+	}
+
+	c = 0
+	rtbl.Walk6(f)
+	if c != 4 {
+		t.Errorf("Walk6() - %d: full walk", c)
+	}
+}
+
+func TestNetipWalkAbort(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	// stop after n routes, nothing may be visited after the abort
+	for _, n := range []int{1, 5, 11, 12, 15} {
+		var visited []netip.Prefix
+		rtbl.Walk(func(p netip.Prefix, _ string) bool {
+			visited = append(visited, p)
+			return len(visited) < n
+		})
+		if len(visited) != n {
+			t.Errorf("Walk() - abort after %d, visited %d: %v", n, len(visited), visited)
+		}
+		if n <= 11 {
+			for _, p := range visited {
+				if p.Addr().Is6() {
+					t.Errorf("Walk() - abort after %d in IPv4, visited IPv6 %s", n, p)
+				}
+			}
+		}
+	}
+
+	var c int
+	rtbl.Walk4(func(p netip.Prefix, _ string) bool {
+		c++
+		return false
+	})
+	if c != 1 {
+		t.Errorf("Walk4() - abort, visited %d", c)
+	}
+
+	c = 0
+	rtbl.Walk6(func(p netip.Prefix, _ string) bool {
+		if !p.Addr().Is6() {
+			t.Errorf("Walk6() - visited %s", p)
+		}
+		c++
+		return c < 2
+	})
+	if c != 2 {
+		t.Errorf("Walk6() - abort, visited %d", c)
+	}
+
+	// empty IPv4 tree, abort in IPv6
+	empty4 := ipcritbit.New[string]()
+	empty4.Add(netip.MustParsePrefix("::/0"), "")
+	empty4.Add(netip.MustParsePrefix("2001:db8::/32"), "")
+	c = 0
+	empty4.Walk(func(netip.Prefix, string) bool {
+		c++
+		return false
+	})
+	if c != 1 {
+		t.Errorf("Walk() - abort in IPv6, visited %d", c)
+	}
+}
+
+func TestNetipTyped(t *testing.T) {
+	rtbl := ipcritbit.New[int]()
+
+	cidr4 := netip.MustParsePrefix("192.168.1.0/24")
+	addr4 := netip.MustParseAddr("192.168.1.1")
+
+	if v, ok := rtbl.Get(cidr4); v != 0 || ok {
+		t.Errorf("Get() - phantom: %v, %v", v, ok)
+	}
+	if r, v := rtbl.LookupIP(addr4); r.IsValid() || v != 0 {
+		t.Errorf("LookupIP() - phantom: %v, %v", r, v)
+	}
+
+	rtbl.Add(cidr4, 42)
+
+	if v, ok := rtbl.Get(cidr4); v != 42 || !ok {
+		t.Errorf("Get() - failed: %v, %v", v, ok)
+	}
+	if r, v := rtbl.LookupIP(addr4); r != cidr4 || v != 42 {
+		t.Errorf("LookupIP() - failed: %v, %v", r, v)
+	}
+	if v, ok := rtbl.Delete(cidr4); v != 42 || !ok {
+		t.Errorf("Delete() - failed: %v, %v", v, ok)
+	}
+}
+
+func TestNetipCanonical(t *testing.T) {
+	rtbl := ipcritbit.New[string]()
+
+	sloppy := netip.MustParsePrefix("10.1.2.3/8")
+	canon := netip.MustParsePrefix("10.0.0.0/8")
+
+	rtbl.Add(sloppy, "sloppy")
+	rtbl.Add(canon, "canon")
+
+	if s := rtbl.Size(); s != 1 {
+		t.Errorf("Add() - phantom route, size: %d", s)
+	}
+	if v, ok := rtbl.Get(sloppy); v != "canon" || !ok {
+		t.Errorf("Get() - sloppy: %v, %v", v, ok)
+	}
+	rtbl.Walk(func(p netip.Prefix, _ string) bool {
+		if p != canon {
+			t.Errorf("Walk() - expected [%s], actual [%s]", canon, p)
+		}
+		return true
+	})
+
+	// the mask check in lookup must see the masked key
+	if r, v := rtbl.LookupIP(netip.MustParseAddr("10.200.0.1")); r != canon || v != "canon" {
+		t.Errorf("LookupIP() - expected [%s], actual [%s]", canon, r)
+	}
+	if r, _ := rtbl.LookupCIDR(netip.MustParsePrefix("10.1.2.3/16")); r != canon {
+		t.Errorf("LookupCIDR() - expected [%s], actual [%s]", canon, r)
+	}
+
+	// host bits in the lookup key beyond the stored mask
+	sub := netip.MustParsePrefix("10.1.0.0/16")
+	rtbl.Add(sub, "sub")
+	if r, _ := rtbl.LookupCIDR(netip.MustParsePrefix("10.1.255.255/12")); r != canon {
+		t.Errorf("LookupCIDR() - expected [%s], actual [%s]", canon, r)
+	}
+	if r, _ := rtbl.LookupCIDR(netip.MustParsePrefix("10.1.255.255/20")); r != sub {
+		t.Errorf("LookupCIDR() - expected [%s], actual [%s]", sub, r)
+	}
+
+	if v, ok := rtbl.Delete(netip.MustParsePrefix("10.9.9.9/8")); v != "canon" || !ok {
+		t.Errorf("Delete() - sloppy: %v, %v", v, ok)
+	}
+	if s := rtbl.Size(); s != 1 {
+		t.Errorf("Delete() - size: %d", s)
+	}
+}
+
+func TestNetipStrict(t *testing.T) {
+	rtbl := ipcritbit.NewStrict[string]()
+
+	sloppy := netip.MustParsePrefix("2001:db8::1/32")
+	canon := netip.MustParsePrefix("2001:db8::/32")
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Add() - strict: non-canonical prefix accepted")
+			}
+		}()
+		rtbl.Add(sloppy, "sloppy")
+	}()
+
+	rtbl.Add(canon, "canon")
+
+	if v, ok := rtbl.Get(sloppy); ok {
+		t.Errorf("Get() - strict: %v, %v", v, ok)
+	}
+	if r, _ := rtbl.LookupCIDR(sloppy); r.IsValid() {
+		t.Errorf("LookupCIDR() - strict: %v", r)
+	}
+	if _, ok := rtbl.Delete(sloppy); ok {
+		t.Error("Delete() - strict: non-canonical prefix deleted")
+	}
+	if r, _ := rtbl.LookupIP(sloppy.Addr()); r != canon {
+		t.Errorf("LookupIP() - strict: expected [%s], actual [%s]", canon, r)
+	}
+	if v, ok := rtbl.Get(canon); v != "canon" || !ok {
+		t.Errorf("Get() - strict: %v, %v", v, ok)
+	}
+}
+
+func TestNetipErrors(t *testing.T) {
+	rtbl := ipcritbit.New[string]()
+
+	var zero netip.Prefix
+	if err := rtbl.TryAdd(zero, "zero"); !errors.Is(err, ipcritbit.ErrInvalidPrefix) {
+		t.Errorf("TryAdd() - expected ErrInvalidPrefix, got: %v", err)
+	}
+	if _, _, err := rtbl.TryGet(zero); !errors.Is(err, ipcritbit.ErrInvalidPrefix) {
+		t.Errorf("TryGet() - expected ErrInvalidPrefix, got: %v", err)
+	}
+	if _, _, err := rtbl.TryDelete(zero); !errors.Is(err, ipcritbit.ErrInvalidPrefix) {
+		t.Errorf("TryDelete() - expected ErrInvalidPrefix, got: %v", err)
+	}
+	if s := rtbl.Size(); s != 0 {
+		t.Errorf("TryAdd() - invalid prefix stored, size: %d", s)
+	}
+
+	cidr := netip.MustParsePrefix("10.0.0.0/8")
+	if err := rtbl.TryAdd(cidr, "cidr"); err != nil {
+		t.Errorf("TryAdd() - unexpected error: %v", err)
+	}
+	if v, ok, err := rtbl.TryGet(cidr); v != "cidr" || !ok || err != nil {
+		t.Errorf("TryGet() - failed: %v, %v, %v", v, ok, err)
+	}
+	if v, ok, err := rtbl.TryDelete(cidr); v != "cidr" || !ok || err != nil {
+		t.Errorf("TryDelete() - failed: %v, %v, %v", v, ok, err)
+	}
+
+	// no panics, clean misses
+	rtbl.Add(netip.MustParsePrefix("::/0"), "default")
+	if v, ok := rtbl.Get(zero); v != "" || ok {
+		t.Errorf("Get() - invalid prefix: %v, %v", v, ok)
+	}
+	if v, ok := rtbl.Delete(zero); v != "" || ok {
+		t.Errorf("Delete() - invalid prefix: %v, %v", v, ok)
+	}
+	if r, v := rtbl.LookupCIDR(zero); r.IsValid() || v != "" {
+		t.Errorf("LookupCIDR() - invalid prefix: %v, %v", r, v)
+	}
+	if r, v := rtbl.LookupIP(netip.Addr{}); r.IsValid() || v != "" {
+		t.Errorf("LookupIP() - invalid address: %v, %v", r, v)
+	}
+	if r, _ := rtbl.LookupIP(netip.MustParseAddr("fe80::1%eth0")); r.String() != "::/0" {
+		t.Errorf("LookupIP() - zoned address: %v", r)
+	}
+
+	func() {
+		defer func() {
+			if e, _ := recover().(error); !errors.Is(e, ipcritbit.ErrInvalidPrefix) {
+				t.Errorf("Add() - expected panic with ErrInvalidPrefix, got: %v", e)
+			}
+		}()
+		rtbl.Add(zero, "zero")
+	}()
+
+	strict := ipcritbit.NewStrict[string]()
+	sloppy := netip.MustParsePrefix("10.1.2.3/8")
+	if err := strict.TryAdd(sloppy, "sloppy"); !errors.Is(err, ipcritbit.ErrNonCanonicalPrefix) {
+		t.Errorf("TryAdd() - strict: expected ErrNonCanonicalPrefix, got: %v", err)
+	}
+	if _, _, err := strict.TryGet(sloppy); !errors.Is(err, ipcritbit.ErrNonCanonicalPrefix) {
+		t.Errorf("TryGet() - strict: expected ErrNonCanonicalPrefix, got: %v", err)
+	}
+	if _, _, err := strict.TryDelete(sloppy); !errors.Is(err, ipcritbit.ErrNonCanonicalPrefix) {
+		t.Errorf("TryDelete() - strict: expected ErrNonCanonicalPrefix, got: %v", err)
+	}
+}
+
+func TestNetipLookupOK(t *testing.T) {
+	rtbl := ipcritbit.New[*string]()
+
+	addr := netip.MustParseAddr("192.168.1.1")
+	cidr := netip.MustParsePrefix("192.168.1.0/28")
+	route := netip.MustParsePrefix("192.168.0.0/16")
+
+	if r, v, ok := rtbl.Lookup(addr); ok || r.IsValid() || v != nil {
+		t.Errorf("Lookup() - phantom: %v, %v, %v", r, v, ok)
+	}
+	if r, v, ok := rtbl.LookupPrefix(cidr); ok || r.IsValid() || v != nil {
+		t.Errorf("LookupPrefix() - phantom: %v, %v, %v", r, v, ok)
+	}
+
+	// a nil value is a legitimate payload
+	rtbl.Add(route, nil)
+
+	if r, v, ok := rtbl.Lookup(addr); !ok || r != route || v != nil {
+		t.Errorf("Lookup() - failed: %v, %v, %v", r, v, ok)
+	}
+	if r, v, ok := rtbl.LookupPrefix(cidr); !ok || r != route || v != nil {
+		t.Errorf("LookupPrefix() - failed: %v, %v, %v", r, v, ok)
+	}
+	if _, _, ok := rtbl.Lookup(netip.MustParseAddr("10.0.0.1")); ok {
+		t.Error("Lookup() - phantom route for 10.0.0.1")
+	}
+	if _, _, ok := rtbl.LookupPrefix(netip.MustParsePrefix("192.0.0.0/8")); ok {
+		t.Error("LookupPrefix() - phantom route for 192.0.0.0/8")
+	}
+	if _, _, ok := rtbl.Lookup(netip.Addr{}); ok {
+		t.Error("Lookup() - phantom route for invalid address")
+	}
+}
+
+func TestNetipZeroAllocs(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	addr4 := netip.MustParseAddr("192.168.1.35")
+	addr6 := netip.MustParseAddr("2001:db8::1")
+	cidr4 := netip.MustParsePrefix("192.168.1.32/30")
+	cidr6 := netip.MustParsePrefix("2001:db8::/64")
+
+	checks := map[string]func(){
+		"LookupIP4":    func() { rtbl.LookupIP(addr4) },
+		"LookupIP6":    func() { rtbl.LookupIP(addr6) },
+		"Lookup":       func() { rtbl.Lookup(addr4) },
+		"LookupCIDR4":  func() { rtbl.LookupCIDR(cidr4) },
+		"LookupCIDR6":  func() { rtbl.LookupCIDR(cidr6) },
+		"LookupPrefix": func() { rtbl.LookupPrefix(cidr4) },
+		"Get4":         func() { rtbl.Get(cidr4) },
+		"Get6":         func() { rtbl.Get(cidr6) },
+		"Contains4":    func() { rtbl.Contains(addr4) },
+		"Contains6":    func() { rtbl.Contains(addr6) },
+		"ContainsPfx":  func() { rtbl.ContainsPrefix(cidr4) },
+	}
+	for name, f := range checks {
+		if n := testing.AllocsPerRun(100, f); n != 0 {
+			t.Errorf("%s() - allocs per run: %v", name, n)
+		}
+	}
+}
+
+func TestNetipContains(t *testing.T) {
+	rtbl := ipcritbit.New[string]()
+
+	for _, s := range []string{"10.0.0.1", "2001:db8::1"} {
+		if rtbl.Contains(netip.MustParseAddr(s)) {
+			t.Errorf("Contains() - empty table: %s", s)
+		}
+	}
+
+	rtbl = buildTestNetip(t)
+	rtbl.Delete(netip.MustParsePrefix("::/0"))
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.255.255.255", true},
+		{"11.0.0.0", false},
+		{"192.168.1.1", true},
+		{"192.169.0.0", false},
+		{"2001:db8:1::", true},
+		{"fe80::1%eth0", true},
+		{"fec0::1", false},
+		{"::1", false},
+	}
+	for _, tt := range tests {
+		if got := rtbl.Contains(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("Contains(%s) - expected %v, got %v", tt.ip, tt.want, got)
+		}
+	}
+	if rtbl.Contains(netip.Addr{}) {
+		t.Error("Contains() - invalid address")
+	}
+
+	ptests := []struct {
+		pfx  string
+		want bool
+	}{
+		{"10.0.0.0/8", true},
+		{"10.0.0.0/7", false},
+		{"192.168.1.0/25", true},
+		{"192.0.0.0/8", false},
+		{"2001:db8::/48", true},
+		{"2001::/16", false},
+	}
+	for _, tt := range ptests {
+		if got := rtbl.ContainsPrefix(netip.MustParsePrefix(tt.pfx)); got != tt.want {
+			t.Errorf("ContainsPrefix(%s) - expected %v, got %v", tt.pfx, tt.want, got)
+		}
+	}
+	if rtbl.ContainsPrefix(netip.Prefix{}) {
+		t.Error("ContainsPrefix() - invalid prefix")
+	}
+}
+
+func TestNetipLookupAll(t *testing.T) {
+	rtbl := buildTestNetip(t)
+	rtbl.Add(netip.MustParsePrefix("0.0.0.0/0"), "0.0.0.0/0")
+
+	collect := func(walk func(func(netip.Prefix, string) bool)) []string {
+		var got []string
+		walk(func(p netip.Prefix, v string) bool {
+			if p.String() != v {
+				t.Errorf("value mismatch: %s, %s", p, v)
+			}
+			got = append(got, p.String())
+			return true
+		})
+		return got
+	}
+
+	tests := []struct {
+		ip   string
+		want []string
+	}{
+		{"192.168.1.0", []string{"192.168.1.0/32", "192.168.1.0/28", "192.168.1.0/24", "192.168.0.0/16", "0.0.0.0/0"}},
+		{"192.168.1.33", []string{"192.168.1.32/30", "192.168.1.32/27", "192.168.1.0/24", "192.168.0.0/16", "0.0.0.0/0"}},
+		{"10.1.1.1", []string{"10.0.0.0/8", "0.0.0.0/0"}},
+		{"11.1.1.1", []string{"0.0.0.0/0"}},
+		{"2001:db8::1", []string{"2001:db8::/64", "2001:db8::/32", "::/0"}},
+		{"fe80::1", []string{"fe80::/10", "::/0"}},
+	}
+	for _, tt := range tests {
+		ip := netip.MustParseAddr(tt.ip)
+		got := collect(func(cb func(netip.Prefix, string) bool) { rtbl.LookupAll(ip, cb) })
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("LookupAll(%s) - expected %v, got %v", tt.ip, tt.want, got)
+		}
+	}
+
+	pfx := netip.MustParsePrefix("192.168.1.0/28")
+	want := []string{"192.168.1.0/28", "192.168.1.0/24", "192.168.0.0/16", "0.0.0.0/0"}
+	got := collect(func(cb func(netip.Prefix, string) bool) { rtbl.WalkSupernets(pfx, cb) })
+	if !slices.Equal(got, want) {
+		t.Errorf("WalkSupernets(%s) - expected %v, got %v", pfx, want, got)
+	}
+
+	// abort
+	var n int
+	rtbl.LookupAll(netip.MustParseAddr("192.168.1.0"), func(netip.Prefix, string) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("LookupAll() - abort, callback called %d times", n)
+	}
+
+	// empty and invalid
+	empty := ipcritbit.New[string]()
+	if got := collect(func(cb func(netip.Prefix, string) bool) { empty.LookupAll(netip.MustParseAddr("::1"), cb) }); got != nil {
+		t.Errorf("LookupAll() - empty table: %v", got)
+	}
+	if got := collect(func(cb func(netip.Prefix, string) bool) { rtbl.LookupAll(netip.Addr{}, cb) }); got != nil {
+		t.Errorf("LookupAll() - invalid address: %v", got)
+	}
+}
+
+func TestNetipLookupAllRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	rtbl := ipcritbit.New[int]()
+	var pfxs []netip.Prefix
+	for i := 0; i < 2000; i++ {
+		// few leading bits for dense nesting
+		ip := random.Int31() & 0x0f0f_ffff
+		a4 := [4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}
+		p := netip.PrefixFrom(netip.AddrFrom4(a4), random.Intn(33)).Masked()
+		rtbl.Add(p, p.Bits())
+		pfxs = append(pfxs, p)
+	}
+
+	for i := 0; i < 2000; i++ {
+		ip := random.Int31() & 0x0f0f_ffff
+		addr := netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)})
+
+		var want []netip.Prefix
+		for _, p := range pfxs {
+			if p.Contains(addr) && !slices.Contains(want, p) {
+				want = append(want, p)
+			}
+		}
+		slices.SortFunc(want, func(a, b netip.Prefix) int { return b.Bits() - a.Bits() })
+
+		var got []netip.Prefix
+		rtbl.LookupAll(addr, func(p netip.Prefix, _ int) bool {
+			got = append(got, p)
+			return true
+		})
+		if !slices.Equal(got, want) {
+			t.Fatalf("LookupAll(%s) - expected %v, got %v", addr, want, got)
+		}
+	}
+}
+
+func TestNetipWalkSubnets(t *testing.T) {
+	rtbl := buildTestNetip(t)
+
+	tests := []struct {
+		pfx  string
+		want []string
+	}{
+		{"192.168.1.0/24", []string{"192.168.1.0/24", "192.168.1.0/28", "192.168.1.0/32", "192.168.1.1/32", "192.168.1.2/32", "192.168.1.32/27", "192.168.1.32/30"}},
+		{"192.168.1.0/26", []string{"192.168.1.0/28", "192.168.1.0/32", "192.168.1.1/32", "192.168.1.2/32", "192.168.1.32/27", "192.168.1.32/30"}},
+		{"192.168.2.0/24", []string{"192.168.2.1/32", "192.168.2.2/32"}},
+		{"192.168.1.1/32", []string{"192.168.1.1/32"}},
+		{"192.168.3.0/24", nil},
+		{"10.0.0.0/16", nil},
+		{"0.0.0.0/0", []string{"10.0.0.0/8", "192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/28", "192.168.1.0/32", "192.168.1.1/32", "192.168.1.2/32", "192.168.1.32/27", "192.168.1.32/30", "192.168.2.1/32", "192.168.2.2/32"}},
+		{"2001:db8::/16", []string{"2001:db8::/32", "2001:db8::/64"}},
+		{"2001:db8::/48", []string{"2001:db8::/64"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		rtbl.WalkSubnets(netip.MustParsePrefix(tt.pfx), func(p netip.Prefix, v string) bool {
+			got = append(got, v)
+			return true
+		})
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("WalkSubnets(%s) - expected %v, got %v", tt.pfx, tt.want, got)
+		}
+	}
+}
+
+func TestNetipWalkSubnetsRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	rtbl := ipcritbit.New[int]()
+	for i := 0; i < 2000; i++ {
+		ip := random.Int31() & 0x0f0f_ffff
+		a4 := [4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}
+		rtbl.Add(netip.PrefixFrom(netip.AddrFrom4(a4), random.Intn(33)), 0)
+	}
+
+	var all []netip.Prefix
+	rtbl.Walk(func(p netip.Prefix, _ int) bool {
+		all = append(all, p)
+		return true
+	})
+
+	for i := 0; i < 500; i++ {
+		ip := random.Int31() & 0x0f0f_ffff
+		a4 := [4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}
+		pfx := netip.PrefixFrom(netip.AddrFrom4(a4), random.Intn(25)).Masked()
+
+		var want []netip.Prefix
+		for _, p := range all {
+			if p.Bits() >= pfx.Bits() && pfx.Contains(p.Addr()) {
+				want = append(want, p)
+			}
+		}
+
+		var got []netip.Prefix
+		rtbl.WalkSubnets(pfx, func(p netip.Prefix, _ int) bool {
+			got = append(got, p)
+			return true
+		})
+		if !slices.Equal(got, want) {
+			t.Fatalf("WalkSubnets(%s) - expected %v, got %v", pfx, want, got)
+		}
+	}
+}
+
+func TestNetipOverlapsPrefix(t *testing.T) {
+	rtbl := buildTestNetip(t)
+	rtbl.Delete(netip.MustParsePrefix("::/0"))
+
+	tests := []struct {
+		pfx  string
+		want bool
+	}{
+		{"10.0.0.0/8", true},
+		{"10.1.0.0/16", true},
+		{"8.0.0.0/6", true},
+		{"11.0.0.0/8", false},
+		{"192.168.3.0/24", true},
+		{"192.169.0.0/16", false},
+		{"192.0.0.0/8", true},
+		{"0.0.0.0/0", true},
+		{"2001:db8::/48", true},
+		{"2001::/16", true},
+		{"2002::/16", false},
+		{"fe00::/8", true},
+		{"fec0::/10", false},
+	}
+	for _, tt := range tests {
+		if got := rtbl.OverlapsPrefix(netip.MustParsePrefix(tt.pfx)); got != tt.want {
+			t.Errorf("OverlapsPrefix(%s) - expected %v, got %v", tt.pfx, tt.want, got)
+		}
+	}
+	if rtbl.OverlapsPrefix(netip.Prefix{}) {
+		t.Error("OverlapsPrefix() - invalid prefix")
+	}
+}
+
+func TestNetipOverlaps(t *testing.T) {
+	build := func(ss ...string) ipcritbit.RouteTable[int] {
+		rtbl := ipcritbit.New[int]()
+		for _, s := range ss {
+			rtbl.Add(netip.MustParsePrefix(s), 0)
+		}
+		return rtbl
+	}
+
+	tests := []struct {
+		a, b []string
+		want bool
+	}{
+		{nil, nil, false},
+		{[]string{"10.0.0.0/8"}, nil, false},
+		{[]string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, true},
+		{[]string{"10.0.0.0/8"}, []string{"11.0.0.0/8", "9.0.0.0/8"}, false},
+		{[]string{"10.0.0.0/8", "10.1.0.0/16", "10.1.1.0/24"}, []string{"11.0.0.0/8", "12.0.0.0/8", "10.1.1.128/25"}, true},
+		{[]string{"10.0.0.0/8"}, []string{"::/0"}, false},
+		{[]string{"2001:db8::/32"}, []string{"::/0"}, true},
+	}
+	for _, tt := range tests {
+		a, b := build(tt.a...), build(tt.b...)
+		if got := a.Overlaps(b); got != tt.want {
+			t.Errorf("Overlaps(%v, %v) - expected %v, got %v", tt.a, tt.b, tt.want, got)
+		}
+		if got := b.Overlaps(a); got != tt.want {
+			t.Errorf("Overlaps(%v, %v) - expected %v, got %v", tt.b, tt.a, tt.want, got)
+		}
+	}
+}
+
+func TestNetipOverlapsRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	gen := func(n int) ([]netip.Prefix, ipcritbit.RouteTable[int]) {
+		rtbl := ipcritbit.New[int]()
+		var pfxs []netip.Prefix
+		for i := 0; i < n; i++ {
+			ip := random.Int31()
+			a4 := [4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}
+			p := netip.PrefixFrom(netip.AddrFrom4(a4), 8+random.Intn(25)).Masked()
+			pfxs = append(pfxs, p)
+			rtbl.Add(p, 0)
+		}
+		return pfxs, rtbl
+	}
+
+	for i := 0; i < 200; i++ {
+		pa, a := gen(1 + random.Intn(20))
+		pb, b := gen(1 + random.Intn(20))
+
+		var want bool
+		for _, p := range pa {
+			for _, q := range pb {
+				want = want || p.Overlaps(q)
+			}
+			if got := b.OverlapsPrefix(p); got != slices.ContainsFunc(pb, p.Overlaps) {
+				t.Fatalf("OverlapsPrefix(%s) - got %v, tables %v", p, got, pb)
+			}
+		}
+		if got := a.Overlaps(b); got != want {
+			t.Fatalf("Overlaps() - expected %v, got %v: %v, %v", want, got, pa, pb)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", 0},
+		{"10.0.0.0/8", "10.0.0.0/16", -1},
+		{"10.0.0.0/16", "10.0.0.0/8", 1},
+		{"10.0.0.0/16", "10.1.0.0/16", -1},
+		{"10.0.0.0/24", "10.1.0.0/16", -1},
+		{"8.0.0.0/7", "9.0.0.0/8", -1},
+		{"255.255.255.255/32", "::/0", -1},
+		{"::/0", "0.0.0.0/0", 1},
+		{"::ffff:10.0.0.0/104", "10.0.0.0/8", 1},
+		{"2001:db8::/32", "2001:db8::/64", -1},
+		{"fe80::/10", "2001:db8::/32", 1},
+	}
+	for _, tt := range tests {
+		a, b := netip.MustParsePrefix(tt.a), netip.MustParsePrefix(tt.b)
+		if got := ipcritbit.Compare(a, b); got != tt.want {
+			t.Errorf("Compare(%s, %s) - expected %d, got %d", a, b, tt.want, got)
+		}
+	}
+}
+
+func TestNetipWalkOrder(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	rtbl := ipcritbit.New[int]()
+	var want []netip.Prefix
+	for i := 0; i < 5000; i++ {
+		var addr netip.Addr
+		var bits int
+		if random.Intn(2) == 0 {
+			var a4 [4]byte
+			random.Read(a4[:2])
+			addr, bits = netip.AddrFrom4(a4), random.Intn(33)
+		} else {
+			var a16 [16]byte
+			random.Read(a16[:3])
+			addr, bits = netip.AddrFrom16(a16), random.Intn(129)
+		}
+		p := netip.PrefixFrom(addr, bits).Masked()
+		if _, ok := rtbl.Get(p); !ok {
+			want = append(want, p)
+		}
+		rtbl.Add(p, i)
+	}
+	slices.SortFunc(want, ipcritbit.Compare)
+
+	var got []netip.Prefix
+	rtbl.Walk(func(p netip.Prefix, _ int) bool {
+		got = append(got, p)
+		return true
+	})
+	if !slices.Equal(got, want) {
+		t.Error("Walk() - not in canonical order")
+	}
+
+	got = slices.Collect(func(yield func(netip.Prefix) bool) {
+		for p := range rtbl.All() {
+			if !yield(p) {
+				return
+			}
+		}
+	})
+	if !slices.IsSortedFunc(got, ipcritbit.Compare) {
+		t.Error("All() - not in canonical order")
+	}
+}
+
+func TestNetipClone(t *testing.T) {
+	rtbl := buildTestNetip(t)
+	want := rtbl.String()
+
+	clone := rtbl.Clone()
+	if clone.String() != want {
+		t.Errorf("Clone() - expected:\n%s\ngot:\n%s", want, clone)
+	}
+
+	clone.Add(netip.MustParsePrefix("172.16.0.0/12"), "new")
+	clone.Delete(netip.MustParsePrefix("10.0.0.0/8"))
+	clone.Add(netip.MustParsePrefix("::/0"), "changed")
+	if rtbl.String() != want {
+		t.Errorf("Clone() - original modified:\n%s", rtbl)
+	}
+	if clone.Size() != rtbl.Size() {
+		t.Errorf("Clone() - expected size %d, got %d", rtbl.Size(), clone.Size())
+	}
+
+	// pointer values
+	type route struct{ metric int }
+	ptbl := ipcritbit.New[*route]()
+	cidr := netip.MustParsePrefix("10.0.0.0/8")
+	ptbl.Add(cidr, &route{metric: 1})
+
+	shallow := ptbl.Clone()
+	deep := ptbl.CloneFunc(func(r *route) *route {
+		c := *r
+		return &c
+	})
+	v, _ := deep.Get(cidr)
+	v.metric = 2
+	if v, _ := ptbl.Get(cidr); v.metric != 1 {
+		t.Errorf("CloneFunc() - original value modified: %d", v.metric)
+	}
+	if s, _ := shallow.Get(cidr); s == v {
+		t.Error("CloneFunc() - value not copied")
+	}
+
+	strict := ipcritbit.NewStrict[int]().Clone()
+	if err := strict.TryAdd(netip.MustParsePrefix("10.1.2.3/8"), 0); !errors.Is(err, ipcritbit.ErrNonCanonicalPrefix) {
+		t.Errorf("Clone() - strict mode lost: %v", err)
+	}
 }
@@ -0,0 +1,122 @@
+package ipcritbit
+
+import (
+	"io"
+	"iter"
+	"net/netip"
+)
+
+// PersistentTable is an immutable IP routing table, generic over the value
+// type V.
+//
+// Insert and Delete return a new table and leave the original unchanged.
+// Only the nodes on the path to the modified route are copied, all other
+// nodes are shared between the versions. Old versions stay valid for
+// rollback and may be handed to readers in other goroutines without locks.
+type PersistentTable[V any] struct {
+	tbl RouteTable[V]
+}
+
+// Create persistent IP routing table.
+func NewPersistent[V any]() PersistentTable[V] {
+	return PersistentTable[V]{tbl: New[V]()}
+}
+
+// Insert returns a new table with the route added or replaced.
+// Insert panics if p is invalid. Use TryInsert for untrusted input.
+func (t PersistentTable[V]) Insert(p netip.Prefix, value V) PersistentTable[V] {
+	nt, err := t.TryInsert(p, value)
+	if err != nil {
+		panic(err)
+	}
+	return nt
+}
+
+// TryInsert is like Insert, returning ErrInvalidPrefix instead of panicking.
+func (t PersistentTable[V]) TryInsert(p netip.Prefix, value V) (PersistentTable[V], error) {
+	p, err := t.tbl.canonical(p)
+	if err != nil {
+		return t, err
+	}
+	var buf [maxKeyLen]byte
+	key := pfxToKey(&buf, p)
+	if p.Addr().Is4() {
+		t.tbl.tree4 = t.tbl.tree4.setPersist(key, value)
+	} else {
+		t.tbl.tree6 = t.tbl.tree6.setPersist(key, value)
+	}
+	return t, nil
+}
+
+// Delete returns a new table without the route, ok reports whether p was
+// found. If not, t itself is returned.
+// An invalid prefix is reported as a miss.
+func (t PersistentTable[V]) Delete(p netip.Prefix) (_ PersistentTable[V], value V, ok bool) {
+	p, err := t.tbl.canonical(p)
+	if err != nil {
+		return t, value, false
+	}
+	var buf [maxKeyLen]byte
+	key := pfxToKey(&buf, p)
+	if p.Addr().Is4() {
+		t.tbl.tree4, value, ok = t.tbl.tree4.deletePersist(key)
+	} else {
+		t.tbl.tree6, value, ok = t.tbl.tree6.deletePersist(key)
+	}
+	return t, value, ok
+}
+
+// Get a specific route, see RouteTable.Get.
+func (t PersistentTable[V]) Get(p netip.Prefix) (value V, ok bool) {
+	return t.tbl.Get(p)
+}
+
+// Lookup returns the longest prefix match for ip, see RouteTable.Lookup.
+func (t PersistentTable[V]) Lookup(ip netip.Addr) (route netip.Prefix, value V, ok bool) {
+	return t.tbl.Lookup(ip)
+}
+
+// LookupPrefix returns the longest prefix match for p, see RouteTable.LookupPrefix.
+func (t PersistentTable[V]) LookupPrefix(p netip.Prefix) (route netip.Prefix, value V, ok bool) {
+	return t.tbl.LookupPrefix(p)
+}
+
+// Contains reports whether any route covers ip.
+func (t PersistentTable[V]) Contains(ip netip.Addr) bool {
+	return t.tbl.Contains(ip)
+}
+
+// Returns number of routes.
+func (t PersistentTable[V]) Size() int {
+	return t.tbl.Size()
+}
+
+// Walk iterates all routes in canonical order, see RouteTable.Walk.
+func (t PersistentTable[V]) Walk(callback func(prefix netip.Prefix, value V) bool) {
+	t.tbl.Walk(callback)
+}
+
+// All returns an iterator over all routes in canonical order.
+func (t PersistentTable[V]) All() iter.Seq2[netip.Prefix, V] {
+	return t.tbl.All()
+}
+
+// Supernets returns an iterator over all routes covering p, see RouteTable.Supernets.
+func (t PersistentTable[V]) Supernets(p netip.Prefix) iter.Seq2[netip.Prefix, V] {
+	return t.tbl.Supernets(p)
+}
+
+// Subnets returns an iterator over all routes covered by p, see RouteTable.Subnets.
+func (t PersistentTable[V]) Subnets(p netip.Prefix) iter.Seq2[netip.Prefix, V] {
+	return t.tbl.Subnets(p)
+}
+
+// Fprint writes a hierarchical tree diagram of the routes, see RouteTable.Fprint.
+func (t PersistentTable[V]) Fprint(w io.Writer) error {
+	return t.tbl.Fprint(w)
+}
+
+// String returns the hierarchical tree diagram of the routes.
+func (t PersistentTable[V]) String() string {
+	return t.tbl.String()
+}
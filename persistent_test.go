@@ -0,0 +1,133 @@
+package ipcritbit_test
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func TestPersistent(t *testing.T) {
+	v0 := ipcritbit.NewPersistent[string]()
+
+	cidr := netip.MustParsePrefix("192.168.0.0/16")
+	v1 := v0.Insert(cidr, "v1")
+	v2 := v1.Insert(netip.MustParsePrefix("192.168.1.0/24"), "v2")
+	v3 := v2.Insert(cidr, "v3")
+	v4, val, ok := v3.Delete(cidr)
+
+	if !ok || val != "v3" {
+		t.Errorf("Delete() - failed: %v, %v", val, ok)
+	}
+	for i, tt := range []struct {
+		tbl  ipcritbit.PersistentTable[string]
+		size int
+		want string
+	}{
+		{v0, 0, ""},
+		{v1, 1, "v1"},
+		{v2, 2, "v1"},
+		{v3, 2, "v3"},
+		{v4, 1, ""},
+	} {
+		if tt.tbl.Size() != tt.size {
+			t.Errorf("version %d - expected size %d, got %d", i, tt.size, tt.tbl.Size())
+		}
+		if v, _ := tt.tbl.Get(cidr); v != tt.want {
+			t.Errorf("version %d - expected %q, got %q", i, tt.want, v)
+		}
+	}
+
+	if r, v, ok := v4.Lookup(netip.MustParseAddr("192.168.1.1")); !ok || r.String() != "192.168.1.0/24" || v != "v2" {
+		t.Errorf("Lookup() - failed: %v, %v, %v", r, v, ok)
+	}
+	if _, _, ok := v4.Lookup(netip.MustParseAddr("192.168.2.1")); ok {
+		t.Error("Lookup() - deleted route still visible")
+	}
+
+	if same, _, ok := v4.Delete(cidr); ok || same.Size() != v4.Size() {
+		t.Error("Delete() - phantom delete")
+	}
+	if _, _, ok := v4.Delete(netip.Prefix{}); ok {
+		t.Error("Delete() - invalid prefix")
+	}
+	if _, err := v4.TryInsert(netip.Prefix{}, ""); err == nil {
+		t.Error("TryInsert() - invalid prefix accepted")
+	}
+}
+
+// every version must keep its content while later versions are derived
+func TestPersistentRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+
+	type version struct {
+		tbl  ipcritbit.PersistentTable[int]
+		want map[netip.Prefix]int
+	}
+	pt := ipcritbit.NewPersistent[int]()
+	versions := []version{{pt, map[netip.Prefix]int{}}}
+
+	var pool []netip.Prefix
+	for i := 0; i < 300; i++ {
+		base := versions[random.Intn(len(versions))]
+		want := make(map[netip.Prefix]int, len(base.want))
+		for k, v := range base.want {
+			want[k] = v
+		}
+
+		var nt ipcritbit.PersistentTable[int]
+		if len(pool) > 0 && random.Intn(3) == 0 {
+			p := pool[random.Intn(len(pool))]
+			_, existed := want[p]
+			var ok bool
+			nt, _, ok = base.tbl.Delete(p)
+			if ok != existed {
+				t.Fatalf("Delete(%s) - expected %v, got %v", p, existed, ok)
+			}
+			delete(want, p)
+		} else {
+			p := randomPrefix(random)
+			pool = append(pool, p)
+			nt = base.tbl.Insert(p, i)
+			want[p] = i
+		}
+		versions = append(versions, version{nt, want})
+	}
+
+	for i, v := range versions {
+		if v.tbl.Size() != len(v.want) {
+			t.Fatalf("version %d - expected size %d, got %d", i, len(v.want), v.tbl.Size())
+		}
+		for p, val := range v.tbl.All() {
+			if want, ok := v.want[p]; !ok || want != val {
+				t.Fatalf("version %d - unexpected route %s: %d", i, p, val)
+			}
+		}
+	}
+}
+
+func randomPrefix(random *rand.Rand) netip.Prefix {
+	if random.Intn(2) == 0 {
+		var a4 [4]byte
+		random.Read(a4[:2])
+		return netip.PrefixFrom(netip.AddrFrom4(a4), random.Intn(17)).Masked()
+	}
+	var a16 [16]byte
+	random.Read(a16[:2])
+	return netip.PrefixFrom(netip.AddrFrom16(a16), random.Intn(17)).Masked()
+}
+
+func BenchmarkPersistentInsert(b *testing.B) {
+	pt := ipcritbit.NewPersistent[any]()
+	for _, p := range cidrs[:100_000] {
+		pt = pt.Insert(p, nil)
+	}
+	random := rand.New(rand.NewSource(0))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		pt.Insert(genCIDR(random), nil)
+	}
+}
@@ -0,0 +1,96 @@
+package ipcritbit
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+// SyncTable is an IP routing table safe for concurrent use, made for read
+// heavy workloads.
+//
+// The routes are held in a PersistentTable snapshot behind an atomic
+// pointer. Readers load the current snapshot and never block. Writers are
+// serialized by a mutex, each write derives a new snapshot by path copying
+// and publishes it with an atomic pointer swap (RCU-style).
+type SyncTable[V any] struct {
+	mu   sync.Mutex // serializes writers
+	snap atomic.Pointer[PersistentTable[V]]
+}
+
+// Create concurrency-safe IP routing table.
+func NewSync[V any]() *SyncTable[V] {
+	t := &SyncTable[V]{}
+	pt := NewPersistent[V]()
+	t.snap.Store(&pt)
+	return t
+}
+
+// Snapshot returns the current routes, an immutable table not affected by
+// later writes.
+func (t *SyncTable[V]) Snapshot() PersistentTable[V] {
+	return *t.snap.Load()
+}
+
+// Add a route.
+// Add panics if p is invalid. Use TryAdd for untrusted input.
+func (t *SyncTable[V]) Add(p netip.Prefix, value V) {
+	if err := t.TryAdd(p, value); err != nil {
+		panic(err)
+	}
+}
+
+// TryAdd adds a route, returning ErrInvalidPrefix instead of panicking.
+func (t *SyncTable[V]) TryAdd(p netip.Prefix, value V) (err error) {
+	t.Update(func(pt PersistentTable[V]) PersistentTable[V] {
+		nt, e := pt.TryInsert(p, value)
+		err = e
+		return nt
+	})
+	return
+}
+
+// Delete a specific route.
+// An invalid prefix is reported as a miss.
+func (t *SyncTable[V]) Delete(p netip.Prefix) (value V, ok bool) {
+	t.Update(func(pt PersistentTable[V]) PersistentTable[V] {
+		pt, value, ok = pt.Delete(p)
+		return pt
+	})
+	return
+}
+
+// Update applies fn to the current snapshot and publishes the returned
+// table, all changes of fn become visible to readers at once. Writers are
+// serialized, fn must not call other writing methods of t.
+func (t *SyncTable[V]) Update(fn func(PersistentTable[V]) PersistentTable[V]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	nt := fn(*t.snap.Load())
+	t.snap.Store(&nt)
+}
+
+// Get a specific route, see RouteTable.Get.
+func (t *SyncTable[V]) Get(p netip.Prefix) (value V, ok bool) {
+	return t.snap.Load().Get(p)
+}
+
+// Lookup returns the longest prefix match for ip, see RouteTable.Lookup.
+func (t *SyncTable[V]) Lookup(ip netip.Addr) (route netip.Prefix, value V, ok bool) {
+	return t.snap.Load().Lookup(ip)
+}
+
+// LookupPrefix returns the longest prefix match for p, see RouteTable.LookupPrefix.
+func (t *SyncTable[V]) LookupPrefix(p netip.Prefix) (route netip.Prefix, value V, ok bool) {
+	return t.snap.Load().LookupPrefix(p)
+}
+
+// Contains reports whether any route covers ip.
+func (t *SyncTable[V]) Contains(ip netip.Addr) bool {
+	return t.snap.Load().Contains(ip)
+}
+
+// Returns number of routes.
+func (t *SyncTable[V]) Size() int {
+	return t.snap.Load().Size()
+}
@@ -0,0 +1,157 @@
+package ipcritbit_test
+
+import (
+	"math/rand"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/gaissmai/ipcritbit"
+)
+
+func TestSyncTable(t *testing.T) {
+	st := ipcritbit.NewSync[string]()
+
+	cidr := netip.MustParsePrefix("10.0.0.0/8")
+	st.Add(cidr, "a")
+	snap := st.Snapshot()
+	st.Add(netip.MustParsePrefix("10.1.0.0/16"), "b")
+
+	if r, v, ok := st.Lookup(netip.MustParseAddr("10.1.2.3")); !ok || r.String() != "10.1.0.0/16" || v != "b" {
+		t.Errorf("Lookup() - failed: %v, %v, %v", r, v, ok)
+	}
+	if snap.Size() != 1 || st.Size() != 2 {
+		t.Errorf("Snapshot() - not isolated: %d, %d", snap.Size(), st.Size())
+	}
+	if v, ok := st.Delete(cidr); !ok || v != "a" {
+		t.Errorf("Delete() - failed: %v, %v", v, ok)
+	}
+	if _, ok := st.Get(cidr); ok {
+		t.Error("Get() - deleted route")
+	}
+	if !st.Contains(netip.MustParseAddr("10.1.0.1")) || st.Contains(netip.MustParseAddr("10.2.0.1")) {
+		t.Error("Contains() - failed")
+	}
+	if err := st.TryAdd(netip.Prefix{}, "x"); err == nil {
+		t.Error("TryAdd() - invalid prefix accepted")
+	}
+
+	// batch update, all or nothing visible
+	st.Update(func(pt ipcritbit.PersistentTable[string]) ipcritbit.PersistentTable[string] {
+		pt = pt.Insert(netip.MustParsePrefix("::/0"), "c")
+		return pt.Insert(netip.MustParsePrefix("2001:db8::/32"), "d")
+	})
+	if st.Size() != 3 {
+		t.Errorf("Update() - expected 3 routes, got %d", st.Size())
+	}
+}
+
+// run with -race
+func TestSyncTableConcurrent(t *testing.T) {
+	st := ipcritbit.NewSync[int]()
+	const writes = 2000
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	// writer: routes 10.x.y.0/24 added in order, the value is the count
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < writes; i++ {
+			p := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i >> 8), byte(i), 0}), 24)
+			st.Update(func(pt ipcritbit.PersistentTable[int]) ipcritbit.PersistentTable[int] {
+				return pt.Insert(p, pt.Size()+1)
+			})
+			if i%3 == 0 {
+				st.Delete(p)
+				st.Add(p, st.Size()+1)
+			}
+		}
+	}()
+
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// a snapshot is consistent: the last route carries its size
+				snap := st.Snapshot()
+				var last int
+				for _, v := range snap.All() {
+					last = v
+				}
+				if last != snap.Size() {
+					t.Errorf("Snapshot() - inconsistent: size %d, last value %d", snap.Size(), last)
+					return
+				}
+				i := random.Intn(writes)
+				st.Lookup(netip.AddrFrom4([4]byte{10, byte(i >> 8), byte(i), 1}))
+			}
+		}(int64(r))
+	}
+	wg.Wait()
+
+	if st.Size() != writes {
+		t.Errorf("expected %d routes, got %d", writes, st.Size())
+	}
+}
+
+func BenchmarkSyncLookupParallel(b *testing.B) {
+	st := ipcritbit.NewSync[any]()
+	st.Update(func(pt ipcritbit.PersistentTable[any]) ipcritbit.PersistentTable[any] {
+		for _, p := range cidrs[:100_000] {
+			pt = pt.Insert(p, nil)
+		}
+		return pt
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		random := rand.New(rand.NewSource(0))
+		for pb.Next() {
+			st.Lookup(genCIDR(random).Addr())
+		}
+	})
+}
+
+func BenchmarkSyncLookupParallelWithWriter(b *testing.B) {
+	st := ipcritbit.NewSync[any]()
+	st.Update(func(pt ipcritbit.PersistentTable[any]) ipcritbit.PersistentTable[any] {
+		for _, p := range cidrs[:100_000] {
+			pt = pt.Insert(p, nil)
+		}
+		return pt
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		random := rand.New(rand.NewSource(1))
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				st.Add(genCIDR(random), nil)
+			}
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		random := rand.New(rand.NewSource(0))
+		for pb.Next() {
+			st.Lookup(genCIDR(random).Addr())
+		}
+	})
+}